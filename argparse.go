@@ -50,6 +50,13 @@ func argSetBool(vp *bool) argOptProc {
 	}
 }
 
+func argAddStr(vp *[]string) argOptProc {
+	return func(arg string, opt string) (err error) {
+		*vp = append(*vp, arg)
+		return
+	}
+}
+
 func argSetInt(vp *int64) argOptProc {
 	return func(arg string, opt string) (err error) {
 		*vp, err = readInt(arg)
@@ -85,9 +92,15 @@ func argKeyValue(tok string) (key, value string, ok bool) {
 }
 
 func argParse(infos []argInfo, anonProc argProc) {
+	argParseArgs(os.Args[1:], infos, anonProc)
+}
+
+// argParseArgs is like argParse, but processes the given arguments
+// (excluding the program name) instead of the command line.
+func argParseArgs(args []string, infos []argInfo, anonProc argProc) {
 	usage := argUsageMsg(infos)
-	for i, lmt := 1, len(os.Args); i < lmt; i++ {
-		tok, done := os.Args[i], false
+	for i, lmt := 0, len(args); i < lmt; i++ {
+		tok, done := args[i], false
 		key, val, valok := argKeyValue(tok)
 		if tok == "-help" || tok == "--help" {
 			//NB: '-help=VAL' does not count
@@ -107,7 +120,7 @@ func argParse(infos []argInfo, anonProc argProc) {
 							if i++; i == lmt {
 								argError(usage, "'%s' needs an argument", key)
 							}
-							val = os.Args[i]
+							val = args[i]
 						}
 						if err := info.proc(val, key); err != nil {
 							argValueError(usage, val, err)
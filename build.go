@@ -0,0 +1,288 @@
+// Copyright (c) 2018-2021 Takayuki YATO (aka. "ZR")
+//   GitHub:   https://github.com/zr-tex8r
+//   Twitter:  @zr_tex8r
+// Distributed under the MIT License.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+var (
+	buildDirs     []string
+	buildWorkers  = int64(runtime.NumCPU())
+	buildMarkdown bool
+	buildManifest = manifestName
+)
+
+// The options of build, which come before the usual ones.
+var buildArgSpecList = []argInfo{
+	argInfo{"-j", argInt, argSetInt(&buildWorkers), " Set the number of files built at once (default: number of CPUs)"},
+	argInfo{"--jobs", argInt, argSetInt(&buildWorkers), " Set the number of files built at once (default: number of CPUs)"},
+	argInfo{"--with-markdown", argBool, argSetBool(&buildMarkdown), " Also builds .md files as Markdown"},
+	argInfo{"--manifest", argStr, argSetStr(&buildManifest), " Specify the project manifest used without directories (default: scsatysfi.toml)"},
+}
+
+// buildTask is a job with the name shown in the summary.
+type buildTask struct {
+	name string
+	job  *scJob
+}
+
+// buildResult is a row of the summary.
+type buildResult struct {
+	name    string
+	vtype   string // "-" if unknown
+	outFile string
+	elapsed time.Duration
+	err     error
+}
+
+func runBuild(args []string) {
+	pristine := saveCliOptions()
+	specs := append(append([]argInfo(nil), buildArgSpecList...), argSpecList...)
+	argParseArgs(args, specs, func(arg string) error {
+		buildDirs = append(buildDirs, arg)
+		return nil
+	})
+	if len(inSources) > 0 || outFile != "" || watchMode || rpcMode {
+		scePanic(errors.New("build takes none of --eval, -o, --watch and --rpc."))
+	}
+	if buildWorkers <= 0 {
+		scePanic(fmt.Errorf("illegal number of jobs (%d).", buildWorkers))
+	}
+	if len(buildDirs) > 0 && depFileVal != "" {
+		scePanic(errors.New("build DIR cannot take -M, which the documents would share."))
+	}
+	var tasks []buildTask
+	if len(buildDirs) == 0 {
+		tasks = buildManifestTasks(buildManifest, args, specs, pristine)
+	} else {
+		paths := buildFindFiles(buildDirs)
+		included := buildIncludedFiles(paths)
+		for _, path := range paths {
+			if !included[fullInPath(path)] || buildIsGiven(path) {
+				tasks = append(tasks, buildTask{path, newBuildJob(path)})
+			}
+		}
+		if n := len(paths) - len(tasks); n > 0 {
+			fmt.Printf(" ---- ---- ---- ----\n")
+			fmt.Printf("  skipping %d files included by the others ...\n", n)
+		}
+	}
+	if len(tasks) == 0 {
+		scePanic(errors.New("no input file found."))
+	}
+	buildCheckDepFiles(tasks)
+
+	fmt.Printf(" ---- ---- ---- ----\n")
+	fmt.Printf("  building %d documents with %d workers ...\n", len(tasks), buildWorkers)
+	start := time.Now()
+	results := runBuildTasks(tasks, int(buildWorkers))
+	if !buildReport(results, time.Since(start)) {
+		os.Exit(1)
+	}
+}
+
+// buildFindFiles returns the input files in the directories, sorted.
+// A file given directly is taken as it is.
+func buildFindFiles(dirs []string) (paths []string) {
+	for _, dir := range dirs {
+		err := filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if fi.Mode().IsRegular() && (path == dir || buildIsInput(path)) {
+				paths = append(paths, path)
+			}
+			return nil
+		})
+		sceAssert(err)
+	}
+	sort.Strings(paths)
+	return
+}
+
+// buildIncludedFiles returns the full paths of the files that the
+// others include, which are parts of documents rather than documents.
+// The errors are left to the builds.
+func buildIncludedFiles(paths []string) map[string]bool {
+	included := make(map[string]bool)
+	for _, path := range paths {
+		j := newBuildJob(path)
+		p := j.newParser()
+		sceCatch(func() { j.parseFile(p, path) })
+		for _, inc := range p.includes {
+			included[fullInPath(inc.to)] = true
+		}
+	}
+	return included
+}
+
+// buildIsGiven tells whether the file is given directly rather than
+// found in a directory.
+func buildIsGiven(path string) bool {
+	for _, dir := range buildDirs {
+		if path == dir {
+			return true
+		}
+	}
+	return false
+}
+
+func buildIsInput(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".scty":
+		return true
+	case ".md":
+		return buildMarkdown
+	}
+	return false
+}
+
+// newBuildJob makes the job for an input file, whose output is put
+// beside it.
+func newBuildJob(path string) *scJob {
+	j := newOptionJob()
+	j.sources = []string{path}
+	j.inFile = path
+	j.outFile = changeExt(path, textModeExt[j.textMode])
+	j.markdown = j.markdown || strings.EqualFold(filepath.Ext(path), ".md")
+	buildSetQuiet(j)
+	return j
+}
+
+// buildManifestTasks makes the tasks for the documents in the manifest.
+// The options of each document are parsed from the pristine state, and
+// then those on the command line (args) are applied again.
+func buildManifestTasks(path string, args []string, specs []argInfo, pristine func()) (tasks []buildTask) {
+	if !cfgFileExists(path) {
+		scePanic(fmt.Errorf("no directory designation, and no '%s' found.", path))
+	}
+	var jobs []*scJob
+	targets := readManifest(path, func(t manifestTarget) string {
+		pristine()
+		argParseArgs(append(t.args, args...), specs, func(arg string) error {
+			inSources = append(inSources, arg)
+			return nil
+		})
+		ext := textModeExt[newOptionJob().textMode]
+		if outFile == "" {
+			outFile = t.output + ext
+		}
+		resolveInput(ext)
+		j := newCliJob()
+		buildSetQuiet(j)
+		jobs = append(jobs, j)
+		return j.outFile
+	})
+	for i, t := range targets {
+		tasks = append(tasks, buildTask{t.name, jobs[i]})
+	}
+	return
+}
+
+// buildCheckDepFiles checks that no two tasks write the same
+// dependency file.
+func buildCheckDepFiles(tasks []buildTask) {
+	names := make(map[string]string)
+	for _, t := range tasks {
+		if t.job.depFile == "" {
+			continue
+		}
+		pdep := fullInPath(t.job.depFile)
+		if prev, ok := names[pdep]; ok {
+			scePanic(fmt.Errorf("%s and %s write the same dependency file '%s'.",
+				prev, t.name, ordPath(pdep)))
+		}
+		names[pdep] = t.name
+	}
+}
+
+// buildSetQuiet makes the job print nothing, since the jobs run side
+// by side.
+func buildSetQuiet(j *scJob) {
+	if j.isStdoutMode() {
+		scePanic(fmt.Errorf("build cannot use text mode '%s' for the terminal.", j.textMode))
+	}
+	j.log, j.out = ioutil.Discard, ioutil.Discard
+}
+
+// runBuildTasks builds the tasks with a pool of workers. The results
+// are in the order of the tasks.
+func runBuildTasks(tasks []buildTask, workers int) []buildResult {
+	results := make([]buildResult, len(tasks))
+	queue := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range queue {
+				results[i] = buildOne(tasks[i])
+			}
+		}()
+	}
+	for i := range tasks {
+		queue <- i
+	}
+	close(queue)
+	wg.Wait()
+	return results
+}
+
+func buildOne(t buildTask) buildResult {
+	j := t.job
+	r := buildResult{name: t.name, vtype: "-", outFile: j.outFile}
+	start := time.Now()
+	r.err = sceCatch(func() {
+		j.compile()
+		r.vtype = j.value.vtype.String()
+	})
+	r.elapsed = time.Since(start)
+	if r.err != nil || j.typeCheckOnly {
+		r.outFile = "-"
+	}
+	return r
+}
+
+// buildReport prints the summary table and the errors, and tells
+// whether all the files are built.
+func buildReport(results []buildResult, elapsed time.Duration) bool {
+	failed := 0
+	fmt.Printf(" ---- ---- ---- ----\n")
+	tw := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "  DOCUMENT\tTYPE\tOUTPUT\tTIME\tRESULT\n")
+	for _, r := range results {
+		status := "ok"
+		if r.err != nil {
+			status = "FAILED"
+			failed++
+		}
+		fmt.Fprintf(tw, "  %s\t%s\t%s\t%s\t%s\n", r.name, r.vtype, r.outFile,
+			r.elapsed.Round(time.Millisecond), status)
+	}
+	sceAssert(tw.Flush())
+	for _, r := range results {
+		if r.err != nil {
+			fmt.Printf(" ---- ---- ---- ----\n")
+			fmt.Printf("  %s:\n", r.name)
+			fmt.Print(sceDesc(r.err))
+		}
+	}
+	fmt.Printf(" ---- ---- ---- ----\n")
+	fmt.Printf("  %d documents, %d succeeded, %d failed. (%s)\n", len(results),
+		len(results)-failed, failed, elapsed.Round(time.Millisecond))
+	return failed == 0
+}
@@ -22,9 +22,9 @@ func byteCompile(value scValue) scByteCode {
 	return scByteCode{codeDoEssential}
 }
 
-func byteExec(bcode scByteCode) {
+func (j *scJob) byteExec(bcode scByteCode) {
 	if bytes.Equal(bcode.code, codeDoEssential) {
-		writeOutput(outFile)
+		j.writeOutput(j.outFile)
 	} else {
 		scePanic(errors.New("bad bytecode"))
 	}
@@ -0,0 +1,128 @@
+// Copyright (c) 2018-2021 Takayuki YATO (aka. "ZR")
+//   GitHub:   https://github.com/zr-tex8r
+//   Twitter:  @zr_tex8r
+// Distributed under the MIT License.
+
+package main
+
+import (
+	"errors"
+	"strings"
+)
+
+// The conditional pragmas, which turn lines on or off by the names
+// given by --define:
+//
+//	@@#if draft @@          # on if draft is defined
+//	@@#if level=2 @@        # on if level is defined as 2
+//	@@#else @@
+//	@@#endif @@
+//
+// The lines turned off are taken as if they were in a block comment.
+// Each source must close the conditionals it opens.
+
+// scCondFrame is an open conditional.
+type scCondFrame struct {
+	src        string
+	lno        int
+	bcol, ecol int  // the span of the condition
+	cond       bool // the value of the condition
+	inElse     bool
+}
+
+type scCond struct {
+	defines map[string]string
+	stack   []scCondFrame
+}
+
+func newScCond(defines map[string]string) *scCond {
+	return &scCond{defines: defines}
+}
+
+// enabled tells whether the current line is turned on.
+func (c *scCond) enabled() bool {
+	for _, f := range c.stack {
+		if f.cond == f.inElse {
+			return false
+		}
+	}
+	return true
+}
+
+// pragma processes the pragma kw with arg at acol in a line, and tells
+// whether it is a conditional one; base is the depth at the start of
+// the source. An error is put at the argument, or at the keyword if
+// there is none.
+func (c *scCond) pragma(src string, lno int, kw, arg string, acol, base int) (bool, error) {
+	bcol, ecol := acol, acol+len(arg)
+	if arg == "" { // acol is then just after the keyword
+		bcol, ecol = acol-len(kw), acol
+	}
+	switch kw {
+	case "if":
+		name, val, hasVal := scCondSplit(arg)
+		if name == "" || strings.ContainsAny(arg, " \t") {
+			return true, scePragmaError(src, lno, bcol, ecol, "if needs a NAME or NAME=VALUE")
+		}
+		dval, ok := c.defines[name]
+		cond := ok && (!hasVal || dval == val)
+		c.stack = append(c.stack, scCondFrame{src, lno, bcol, ecol, cond, false})
+	case "else", "endif":
+		if arg != "" {
+			return true, scePragmaError(src, lno, bcol, ecol, kw+" takes no argument")
+		}
+		if len(c.stack) <= base {
+			return true, scePragmaError(src, lno, bcol, ecol, kw+" without if")
+		}
+		top := &c.stack[len(c.stack)-1]
+		if kw == "endif" {
+			c.stack = c.stack[:len(c.stack)-1]
+		} else if top.inElse {
+			return true, scePragmaError(src, lno, bcol, ecol, "else after else")
+		} else {
+			top.inElse = true
+		}
+	default:
+		return false, nil
+	}
+	return true, nil
+}
+
+// finish checks that the conditionals opened after base are closed.
+func (c *scCond) finish(base int) error {
+	if len(c.stack) > base {
+		f := c.stack[base]
+		c.stack = c.stack[:base]
+		return scePragmaError(f.src, f.lno, f.bcol, f.ecol, "if without endif")
+	}
+	return nil
+}
+
+func scCondSplit(arg string) (name, val string, hasVal bool) {
+	if i := strings.IndexByte(arg, '='); i >= 0 {
+		return arg[:i], arg[i+1:], true
+	}
+	return arg, "", false
+}
+
+// scParseDefines makes the table of names from NAME[=VALUE] strings.
+func scParseDefines(defs []string) (map[string]string, error) {
+	defines := make(map[string]string)
+	for _, def := range defs {
+		name, val, _ := scCondSplit(def)
+		if name == "" || strings.ContainsAny(def, " \t") {
+			return nil, errors.New("--define needs NAME or NAME=VALUE.")
+		}
+		defines[name] = val
+	}
+	return defines, nil
+}
+
+// scDisabledLine returns the tokens of a line turned off, which is a
+// comment as a whole.
+func scDisabledLine(line string) []scToken {
+	if line == "" {
+		return nil
+	}
+	return []scToken{scToken{scTokComment, 0, line}}
+}
@@ -0,0 +1,69 @@
+// Copyright (c) 2018-2021 Takayuki YATO (aka. "ZR")
+//   GitHub:   https://github.com/zr-tex8r
+//   Twitter:  @zr_tex8r
+// Distributed under the MIT License.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// The default configuration search path, which comes after the one
+// given by -C option.
+func cfgDefaultPath() []string {
+	var dirs []string
+	if home, err := os.UserHomeDir(); err == nil {
+		dirs = append(dirs, filepath.Join(home, ".scsatysfi"))
+	}
+	return append(dirs, "/usr/local/share/scsatysfi", "/usr/share/scsatysfi")
+}
+
+// cfgSearchPath returns the directories to look for configuration files,
+// in the order of priority.
+func cfgSearchPath(j *scJob) []string {
+	var dirs []string
+	if j.config != "" {
+		for _, dir := range filepath.SplitList(j.config) {
+			if dir != "" {
+				dirs = append(dirs, dir)
+			}
+		}
+	}
+	if !j.noDefaultConfig {
+		dirs = append(dirs, cfgDefaultPath()...)
+	}
+	return dirs
+}
+
+// cfgFindFile looks for a file along the configuration search path,
+// both in each directory and its subdirectory sub (if non-empty).
+// A path that exists as given is used as is.
+func cfgFindFile(j *scJob, name, sub string) (string, error) {
+	if cfgFileExists(name) {
+		j.noteDep(name)
+		return name, nil
+	}
+	var cands []string
+	if !filepath.IsAbs(name) {
+		for _, dir := range cfgSearchPath(j) {
+			if sub != "" {
+				cands = append(cands, filepath.Join(dir, sub, name))
+			}
+			cands = append(cands, filepath.Join(dir, name))
+		}
+	}
+	for _, path := range cands {
+		if cfgFileExists(path) {
+			j.noteDep(path)
+			return path, nil
+		}
+	}
+	return "", sceNotFoundError(name, cands)
+}
+
+func cfgFileExists(path string) bool {
+	fi, err := os.Stat(path)
+	return err == nil && fi.Mode().IsRegular()
+}
@@ -0,0 +1,99 @@
+// Copyright (c) 2018-2021 Takayuki YATO (aka. "ZR")
+//   GitHub:   https://github.com/zr-tex8r
+//   Twitter:  @zr_tex8r
+// Distributed under the MIT License.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// writeDepFile writes a Makefile fragment that makes the output depend
+// on the files read in the build. Each of them also gets an empty rule,
+// so that make does not fail when it is removed.
+func (j *scJob) writeDepFile(pdep string) {
+	buf := new(bytes.Buffer)
+	buf.WriteString(depEscape(j.outFile) + ":")
+	for _, path := range j.deps {
+		buf.WriteString(" \\\n  " + depEscape(path))
+	}
+	buf.WriteString("\n")
+	for _, path := range j.deps {
+		fmt.Fprintf(buf, "\n%s:\n", depEscape(path))
+	}
+	writeBytes(pdep, buf.Bytes())
+
+	fmt.Fprintf(j.log, "  dependencies written on '%s'.\n", ordPath(pdep))
+}
+
+// depEscape quotes a file name in the way make reads it.
+var depEscape = strings.NewReplacer(" ", "\\ ", "#", "\\#", "$", "$$").Replace
+
+// The fill colors of the sources in the dependency graph.
+var depDotColor = map[scVType]string{
+	scNix:       "gray90",
+	scEssential: "lightblue",
+}
+
+// writeDepGraph parses the input sources and prints the graph of the
+// files read in the DOT language of Graphviz, instead of the output.
+// The output file points to the input sources and the other files,
+// and each source to the files it includes, labelled with the line of
+// the pragma. The progress messages are suppressed so that the graph
+// can be piped to dot.
+func (j *scJob) writeDepGraph() {
+	j.log = ioutil.Discard
+	j.value = j.parseFiles(j.sources)
+	if j.template != "" {
+		sctFindTemplate(j)
+	}
+
+	buf := new(bytes.Buffer)
+	buf.WriteString("digraph scsatysfi {\n")
+	buf.WriteString("  node [shape=box, style=filled, fillcolor=white];\n")
+	out := depDotQuote(depDotPath(j.outFile))
+	fmt.Fprintf(buf, "  %s [shape=doubleoctagon];\n", out)
+	seen := make(map[string]bool)
+	node := func(path string) string {
+		id := depDotQuote(depDotPath(path))
+		if !seen[id] {
+			seen[id] = true
+			vtype := j.srcTypes[path]
+			fmt.Fprintf(buf, "  %s [label=%s, fillcolor=%s];\n", id,
+				depDotQuote(depDotPath(path)+"\n"+vtype.String()), depDotColor[vtype])
+		}
+		return id
+	}
+	for _, psrc := range j.sources {
+		fmt.Fprintf(buf, "  %s -> %s;\n", out, node(psrc))
+	}
+	for _, inc := range j.includes {
+		from, to := node(inc.from), node(inc.to)
+		fmt.Fprintf(buf, "  %s -> %s [label=%s];\n", from, to,
+			depDotQuote(fmt.Sprintf("line %d", inc.line)))
+	}
+	for _, path := range j.deps {
+		if _, ok := j.srcTypes[path]; !ok {
+			fmt.Fprintf(buf, "  %s -> %s [style=dashed];\n", out, depDotQuote(depDotPath(path)))
+		}
+	}
+	buf.WriteString("}\n")
+	_, err := j.out.Write(buf.Bytes())
+	sceAssert(err)
+}
+
+func depDotPath(path string) string {
+	if fullPath {
+		return fullInPath(path)
+	}
+	return path
+}
+
+// depDotQuote makes a quoted ID of the DOT language.
+func depDotQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+}
@@ -0,0 +1,107 @@
+// Copyright (c) 2018-2021 Takayuki YATO (aka. "ZR")
+//   GitHub:   https://github.com/zr-tex8r
+//   Twitter:  @zr_tex8r
+// Distributed under the MIT License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/japanese"
+)
+
+// scEncoding is an input encoding; enc is nil for UTF-8.
+type scEncoding struct {
+	name string // shown in diagnostics
+	enc  encoding.Encoding
+}
+
+// The encodings for --input-encoding, whose names are case-insensitive.
+// A byte order mark of UTF-8 or UTF-16 takes precedence over them.
+var scEncodings = map[string]scEncoding{
+	"utf-8":     scEncoding{"UTF-8", nil},
+	"utf8":      scEncoding{"UTF-8", nil},
+	"shift_jis": scEncoding{"Shift_JIS", japanese.ShiftJIS},
+	"sjis":      scEncoding{"Shift_JIS", japanese.ShiftJIS},
+	"euc-jp":    scEncoding{"EUC-JP", japanese.EUCJP},
+	"eucjp":     scEncoding{"EUC-JP", japanese.EUCJP},
+}
+
+func scLookupEncoding(name string) (scEncoding, bool) {
+	if name == "" {
+		name = "utf-8"
+	}
+	e, ok := scEncodings[strings.ToLower(name)]
+	return e, ok
+}
+
+// scDecode converts the content of an input source to UTF-8. Invalid
+// byte sequences are errors, at the position in the decoded text where
+// they would be.
+func scDecode(src string, data []byte, name string) ([]byte, error) {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xEF, 0xBB, 0xBF}):
+		data, name = data[3:], "utf-8"
+	case bytes.HasPrefix(data, []byte{0xFF, 0xFE}):
+		return scDecodeUtf16(src, data[2:], binary.LittleEndian, "UTF-16LE")
+	case bytes.HasPrefix(data, []byte{0xFE, 0xFF}):
+		return scDecodeUtf16(src, data[2:], binary.BigEndian, "UTF-16BE")
+	}
+	e, _ := scLookupEncoding(name)
+	if e.enc == nil {
+		for i := 0; i < len(data); {
+			r, size := utf8.DecodeRune(data[i:])
+			if r == utf8.RuneError && size == 1 {
+				return nil, scBadByteAt(src, data[:i], e.name)
+			}
+			i += size
+		}
+		return data, nil
+	}
+	// the decoders put U+FFFD, which the encodings do not have, for
+	// invalid sequences
+	text, err := e.enc.NewDecoder().Bytes(data)
+	if err != nil {
+		return nil, err
+	}
+	if i := bytes.IndexRune(text, utf8.RuneError); i >= 0 {
+		return nil, scBadByteAt(src, text[:i], e.name)
+	}
+	return text, nil
+}
+
+func scDecodeUtf16(src string, data []byte, order binary.ByteOrder, name string) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	for i := 0; i < len(data); i += 2 {
+		if i+1 == len(data) {
+			return nil, scBadByteAt(src, buf.Bytes(), name)
+		}
+		r := rune(order.Uint16(data[i:]))
+		if utf16.IsSurrogate(r) {
+			r2 := utf8.RuneError
+			if i+3 < len(data) {
+				r2 = rune(order.Uint16(data[i+2:]))
+			}
+			if r = utf16.DecodeRune(r, r2); r == utf8.RuneError {
+				return nil, scBadByteAt(src, buf.Bytes(), name)
+			}
+			i += 2
+		}
+		buf.WriteRune(r)
+	}
+	return buf.Bytes(), nil
+}
+
+// scBadByteAt makes the error of an invalid sequence after the decoded
+// text before.
+func scBadByteAt(src string, before []byte, name string) error {
+	line := bytes.Count(before, []byte{'\n'}) + 1
+	col := len(before) - (bytes.LastIndexByte(before, '\n') + 1)
+	return sceBadByteError(src, line, col, name)
+}
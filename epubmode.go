@@ -0,0 +1,111 @@
+// Copyright (c) 2018-2021 Takayuki YATO (aka. "ZR")
+//   GitHub:   https://github.com/zr-tex8r
+//   Twitter:  @zr_tex8r
+// Distributed under the MIT License.
+
+package main
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"html"
+	"strings"
+	"time"
+)
+
+const scEpubContainerXml = //
+`<?xml version="1.0" encoding="UTF-8"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+<rootfiles>
+<rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/>
+</rootfiles>
+</container>
+`
+
+const scEpubContentOpf = //
+`<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="uid" xml:lang="%[1]s">
+<metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+<dc:identifier id="uid">%[2]s</dc:identifier>
+<dc:title>%[3]s</dc:title>
+<dc:language>%[1]s</dc:language>
+<dc:creator>%[4]s</dc:creator>
+<meta property="dcterms:modified">%[5]s</meta>
+</metadata>
+<manifest>
+<item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>
+<item id="page1" href="page1.xhtml" media-type="application/xhtml+xml"/>
+<item id="snowman" href="snowman.svg" media-type="image/svg+xml"/>
+</manifest>
+<spine>
+<itemref idref="page1"/>
+</spine>
+</package>
+`
+
+const scEpubNavXhtml = //
+`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops" lang="%[1]s" xml:lang="%[1]s">
+<head>
+<meta charset="UTF-8"/>
+<title>%[2]s</title>
+</head>
+<body>
+<nav epub:type="toc" id="toc">
+<ol>
+<li><a href="page1.xhtml">%[2]s</a></li>
+</ol>
+</nav>
+</body>
+</html>
+`
+
+const scEpubPageXhtml = //
+`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" lang="%[1]s" xml:lang="%[1]s">
+<head>
+<meta charset="UTF-8"/>
+<title>%[2]s</title>
+<style>
+body { margin: 0; text-align: center; }
+img { width: 100%%; height: auto; }
+</style>
+</head>
+<body>
+<div><img src="snowman.svg" alt="%[2]s"/></div>
+</body>
+</html>
+`
+
+// scEpubIdentifier makes a name-based (version 5) UUID for the document,
+// so that the same input yields the same identifier.
+func scEpubIdentifier(j *scJob) string {
+	var key []string
+	for _, psrc := range j.sources {
+		key = append(key, fullInPath(psrc))
+	}
+	key = append(key, j.mufflerVal)
+	h := sha1.Sum([]byte(strings.Join(key, "\x00")))
+	h[6] = (h[6] & 0x0F) | 0x50
+	h[8] = (h[8] & 0x3F) | 0x80
+	return fmt.Sprintf("urn:uuid:%x-%x-%x-%x-%x", h[0:4], h[4:6], h[6:8], h[8:10], h[10:16])
+}
+
+func (j *scJob) makeEpubBytes() []byte {
+	title := html.EscapeString(docTitle)
+	modified := time.Now().UTC().Format("2006-01-02T15:04:05Z")
+
+	pkg := newSczPackage()
+	pkg.addMimetype("application/epub+zip")
+	pkg.addString("META-INF/container.xml", scEpubContainerXml)
+	pkg.addString("OEBPS/content.opf", fmt.Sprintf(scEpubContentOpf,
+		docLang, scEpubIdentifier(j), title, html.EscapeString(progName), modified))
+	pkg.addString("OEBPS/nav.xhtml", fmt.Sprintf(scEpubNavXhtml, docLang, title))
+	// the essential document has exactly one page
+	pkg.addString("OEBPS/page1.xhtml", fmt.Sprintf(scEpubPageXhtml, docLang, title))
+	pkg.addString("OEBPS/snowman.svg",
+		"<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n"+makeSnowmanSvg(j.mufflerVal, "", ""))
+	return pkg.bytes()
+}
@@ -8,6 +8,7 @@ package main
 import (
 	"fmt"
 	"os"
+	"strings"
 )
 
 func init() {
@@ -17,12 +18,14 @@ func init() {
 type sceError struct {
 	tag     string
 	message string
+	pos     *scPos // where the error is found, if known
 }
 
 const (
-	sceSynTag  = "Syntax Error at Lexer"
-	sceTypeTag = "Type Error"
-	sceMiscTag = "Error"
+	sceSynTag      = "Syntax Error at Lexer"
+	sceTypeTag     = "Type Error"
+	sceMiscTag     = "Error"
+	sceInternalTag = "Internal Error"
 )
 
 func (e *sceError) Error() string {
@@ -42,9 +45,66 @@ func sceDesc(e error) string {
 	return fmt.Sprintf("! [%v] %v\n", tag, msg)
 }
 
+type sceTrap struct {
+	err error
+}
+
+// scePanic raises err, which ends the program with its message (see
+// sceExit) unless sceCatch recovers from it.
 func scePanic(err error) {
-	fmt.Print(sceDesc(err))
-	os.Exit(1)
+	panic(sceTrap{err})
+}
+
+// sceExit, deferred in main, ends the program for an error given to
+// scePanic. Other panics go on as they are.
+func sceExit() {
+	if r := recover(); r != nil {
+		trap, ok := r.(sceTrap)
+		if !ok {
+			panic(r)
+		}
+		fmt.Print(sceDesc(trap.err))
+		os.Exit(1)
+	}
+}
+
+// sceCatch runs proc and returns the error given to scePanic in it,
+// if any. Any other panic is returned as an internal error, so that a
+// bug in a mode does not bring down a server.
+func sceCatch(proc func()) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if trap, ok := r.(sceTrap); ok {
+				err = trap.err
+			} else {
+				err = sceInternalError(r)
+			}
+		}
+	}()
+	proc()
+	return
+}
+
+// sceDiagnostic is the JSON form of an error.
+type sceDiagnostic struct {
+	Kind    string `json:"kind"`
+	Message string `json:"message"`
+	Source  string `json:"source,omitempty"`
+	Line    int    `json:"line,omitempty"`
+	Column  *int   `json:"column,omitempty"` // nil without a position, so 0 is kept
+}
+
+func sceDiagnosticFor(err error) sceDiagnostic {
+	e, ok := err.(*sceError)
+	if !ok {
+		return sceDiagnostic{Kind: sceMiscTag, Message: err.Error()}
+	}
+	diag := sceDiagnostic{Kind: e.tag, Message: e.message}
+	if e.pos != nil {
+		col := e.pos.col
+		diag.Source, diag.Line, diag.Column = e.pos.src, e.pos.line, &col
+	}
+	return diag
 }
 
 func sceAssert(err error) {
@@ -53,27 +113,94 @@ func sceAssert(err error) {
 	}
 }
 
-func scePosDesc(line, bcol, ecol int, msg string) string {
+func scePosDesc(src string, line, bcol, ecol int, msg string) string {
+	if src == "" {
+		return fmt.Sprintf(
+			"at line %v, characters %v-%v:\n    %v",
+			line, bcol, ecol, msg)
+	}
 	return fmt.Sprintf(
-		"at line %v, characters %v-%v:\n    %v",
-		line, bcol, ecol, msg)
+		"at \"%v\", line %v, characters %v-%v:\n    %v",
+		ordInPath(src), line, bcol, ecol, msg)
 }
 
-func sceBadCharError(line, bcol, ecol int, chr rune) error {
+func sceBadCharError(src string, line, bcol, ecol int, chr rune) error {
 	msg := fmt.Sprintf("invalid character %q(%U)", chr, chr)
-	return &sceError{sceSynTag, scePosDesc(line, bcol, ecol, msg)}
+	return &sceError{sceSynTag, scePosDesc(src, line, bcol, ecol, msg), &scPos{src, line, bcol}}
+}
+
+func sceBadByteError(src string, line, col int, enc string) error {
+	msg := fmt.Sprintf("invalid byte sequence in %s input", enc)
+	return &sceError{sceSynTag, scePosDesc(src, line, col, col+1, msg), &scPos{src, line, col}}
+}
+
+func sceBadClusterError(src string, line, bcol, ecol int, cluster string) error {
+	var cps []string
+	for _, r := range cluster {
+		cps = append(cps, fmt.Sprintf("%U", r))
+	}
+	msg := fmt.Sprintf("unclassifiable character sequence %q(%s)", cluster, strings.Join(cps, " "))
+	return &sceError{sceSynTag, scePosDesc(src, line, bcol, ecol, msg), &scPos{src, line, bcol}}
 }
 
-func sceBadCommentError(line int) error {
+func sceBadCommentError(src string, line int) error {
 	msg := fmt.Sprintf("text input ended while reading a block comment")
-	return &sceError{sceSynTag, scePosDesc(line, 0, 0, msg)}
+	return &sceError{sceSynTag, scePosDesc(src, line, 0, 0, msg), &scPos{src, line, 0}}
+}
+
+func scePragmaError(src string, line, bcol, ecol int, msg string) error {
+	return &sceError{sceSynTag, scePosDesc(src, line, bcol, ecol, msg), &scPos{src, line, bcol}}
+}
+
+func sceIncludeCycleError(src string, line, ecol int, chain []string) error {
+	names := make([]string, len(chain))
+	for i, path := range chain {
+		names[i] = "'" + ordInPath(path) + "'"
+	}
+	msg := "include cycle: " + strings.Join(names, " -> ")
+	return &sceError{sceSynTag, scePosDesc(src, line, 0, ecol, msg), &scPos{src, line, 0}}
 }
 
 func sceNonDocError(path string, vt scVType) error {
 	msg := fmt.Sprintf(
 		"file '%v' is not an essential file; it is of type\n      %v",
 		fullInPath(path), vt)
-	return &sceError{sceTypeTag, msg}
+	return &sceError{sceTypeTag, msg, nil}
+}
+
+func sceXmlInvalidError(err error) error {
+	msg := fmt.Sprintf("XML output does not conform to the schema:\n    %v", err)
+	return &sceError{sceMiscTag, msg, nil}
+}
+
+func sceTomlError(src string, line, col int, msg string) error {
+	return &sceError{sceMiscTag, scePosDesc(src, line, col, col+1, msg), &scPos{src, line, col}}
+}
+
+func sceInternalError(r interface{}) error {
+	return &sceError{sceInternalTag, fmt.Sprint(r), nil}
+}
+
+// sceIsInternal tells whether err is a bug rather than a fault of the
+// input.
+func sceIsInternal(err error) bool {
+	e, ok := err.(*sceError)
+	return ok && e.tag == sceInternalTag
+}
+
+func sceConfigFileError(path, msg string) error {
+	return &sceError{sceMiscTag, fmt.Sprintf("in '%v': %v", ordInPath(path), msg), nil}
+}
+
+func sceNotFoundError(name string, cands []string) error {
+	msg := fmt.Sprintf("cannot find '%v'.", name)
+	if len(cands) > 0 {
+		msg += " candidates:"
+		for _, c := range cands {
+			msg += "\n      " + natFullPath(c)
+		}
+	}
+	return &sceError{sceMiscTag, msg, nil}
 }
 
 //-------- sceUnxDesc
@@ -0,0 +1,224 @@
+// Copyright (c) 2018-2021 Takayuki YATO (aka. "ZR")
+//   GitHub:   https://github.com/zr-tex8r
+//   Twitter:  @zr_tex8r
+// Distributed under the MIT License.
+
+package main
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// The essential figure, as drawn by scpdf. The coordinates are given
+// in the unit square and use the PDF path operators.
+
+const scgBodyCode = // body=false
+`0.5 0.72 m 0.64 0.72 0.76 0.65 0.76 0.55 c
+0.76 0.51 0.72 0.47 0.67 0.44 c 0.79 0.41 0.84 0.32 0.84 0.25 c
+0.84 0.13 0.75 0.08 0.68 0.08 c 0.32 0.08 l
+0.25 0.08 0.16 0.13 0.16 0.25 c 0.16 0.32 0.21 0.41 0.33 0.44 c
+0.28 0.47 0.24 0.51 0.24 0.55 c 0.24 0.65 0.36 0.72 0.5 0.72 c s`
+
+const scgMouthCode = // mouth=true, mouthshape=smile
+`0.40 0.48 m 0.45 0.45 0.55 0.45 0.60 0.48 c S`
+
+const scgHatCode = // hat=true
+`0.58 0.90 m 0.77 0.81 l 0.74 0.61 l 0.66 0.60 0.50 0.66 0.46 0.72 c
+0.58 0.90 l b`
+
+const scgArmsCode = // arms=true
+`0.20 0.31 m 0.19 0.33 0.14 0.41 0.13 0.42 c
+0.12 0.43 0.10 0.43 0.07 0.44 c 0.04 0.46 0.06 0.46 0.08 0.46 c
+0.09 0.46 0.11 0.44 0.12 0.44 c 0.14 0.46 0.14 0.47 0.15 0.49 c
+0.16 0.51 0.16 0.49 0.16 0.48 c 0.16 0.46 0.14 0.44 0.15 0.43 c
+0.16 0.42 0.21 0.35 0.22 0.33 c 0.23 0.31 0.21 0.30 0.20 0.31 c b
+0.80 0.31 m 0.81 0.33 0.86 0.41 0.87 0.42 c
+0.88 0.43 0.90 0.43 0.93 0.44 c 0.96 0.46 0.94 0.46 0.92 0.46 c
+0.91 0.46 0.89 0.44 0.88 0.44 c 0.86 0.46 0.86 0.47 0.85 0.49 c
+0.84 0.51 0.84 0.49 0.84 0.48 c 0.84 0.46 0.86 0.44 0.85 0.43 c
+0.84 0.42 0.79 0.35 0.78 0.33 c 0.77 0.31 0.79 0.30 0.80 0.31 c b`
+
+const scgMufflerCode = // muffler=<color>
+`0.27 0.48 m 0.42 0.38 0.58 0.38 0.73 0.48 c
+0.75 0.46 0.76 0.44 0.77 0.41 c 0.77 0.39 0.75 0.37 0.73 0.36 c
+0.74 0.33 0.74 0.31 0.76 0.26 c 0.75 0.25 0.72 0.24 0.66 0.23 c
+0.66 0.27 0.65 0.30 0.63 0.34 c 0.42 0.30 0.32 0.35 0.24 0.41 c
+0.25 0.45 0.26 0.47 0.27 0.48 c b`
+
+var scgEyesCode = // eyes=true
+scgCircle(0.40, 0.56, 0.02, 0.03, "f") + scgCircle(0.60, 0.56, 0.02, 0.03, "f")
+
+var scgButtonsCode = // buttons=true
+scgCircle(0.50, 0.16, 0.03, 0.03, "b") + scgCircle(0.50, 0.26, 0.03, 0.03, "b")
+
+var scgSnowCode = // snow=true
+scgCircle(0.07, 0.28, 0.04, 0.04, "s") + scgCircle(0.08, 0.68, 0.04, 0.04, "s") +
+	scgCircle(0.13, 0.55, 0.04, 0.04, "s") + scgCircle(0.23, 0.76, 0.04, 0.04, "s") +
+	scgCircle(0.42, 0.89, 0.04, 0.04, "s") + scgCircle(0.74, 0.89, 0.04, 0.04, "s") +
+	scgCircle(0.88, 0.73, 0.04, 0.04, "s") + scgCircle(0.92, 0.53, 0.04, 0.04, "s") +
+	scgCircle(0.94, 0.23, 0.04, 0.04, "s")
+
+// Page layout, which agrees with the defaults of scpdf.
+const (
+	scgPageWidth  = 210 * 72 / 25.4
+	scgPageHeight = 294 * 72 / 25.4
+	scgScale      = 0.6
+	scgLineWidth  = 0.01389 // in the unit square
+)
+
+// Number of straight pieces that approximate one curve segment.
+const scgCurveDiv = 16
+
+//--------scgShape
+
+type scgPoint struct {
+	x, y float64
+}
+
+type scgSegment struct {
+	op  byte // one of 'm', 'l', 'c'
+	pts []scgPoint
+}
+
+type scgShape struct {
+	segs    []scgSegment
+	closed  bool
+	fill    bool
+	stroke  bool
+	muffler bool // painted in the muffler color
+}
+
+var scgShapes []scgShape
+
+func init() {
+	for _, code := range []string{
+		scgBodyCode, scgEyesCode, scgMouthCode, scgHatCode,
+		scgArmsCode, scgButtonsCode, scgSnowCode,
+	} {
+		scgShapes = append(scgShapes, scgParseCode(code, false)...)
+	}
+	scgShapes = append(scgShapes, scgParseCode(scgMufflerCode, true)...)
+}
+
+// scgParseCode reads a PDF path description. Since the code is fixed,
+// malformed input is a bug and causes a panic.
+func scgParseCode(code string, muffler bool) (shapes []scgShape) {
+	var segs []scgSegment
+	var nums []scgPoint
+	var x float64
+	odd := false
+	for _, tok := range strings.Fields(code) {
+		switch tok {
+		case "m", "l", "c":
+			segs = append(segs, scgSegment{tok[0], nums})
+			nums = nil
+		case "s", "S", "f", "b":
+			shape := scgShape{segs: segs, muffler: muffler}
+			shape.closed = (tok != "S")
+			shape.fill = (tok == "f" || tok == "b")
+			shape.stroke = (tok != "f")
+			shapes = append(shapes, shape)
+			segs = nil
+		default:
+			v, err := strconv.ParseFloat(tok, 64)
+			if err != nil {
+				panic(err)
+			}
+			if odd {
+				nums = append(nums, scgPoint{x, v})
+			} else {
+				x = v
+			}
+			odd = !odd
+		}
+	}
+	return
+}
+
+func scgCircle(cx, cy, rx, ry float64, op string) string {
+	str := func(v float64) string {
+		return strconv.FormatFloat(v, 'f', 4, 64)
+	}
+	const a = 0.55228475
+	return strings.Join([]string{
+		str(cx + rx), str(cy), "m",
+		str(cx + rx), str(cy + a*ry), str(cx + a*rx), str(cy + ry), str(cx), str(cy + ry), "c",
+		str(cx - a*rx), str(cy + ry), str(cx - rx), str(cy + a*ry), str(cx - rx), str(cy), "c",
+		str(cx - rx), str(cy - a*ry), str(cx - a*rx), str(cy - ry), str(cx), str(cy - ry), "c",
+		str(cx + a*rx), str(cy - ry), str(cx + rx), str(cy - a*ry), str(cx + rx), str(cy), "c",
+		op, "",
+	}, " ")
+}
+
+//--------scgLayout
+
+// scgLayout returns the side length and the origin of the unit square
+// placed on a page of the given size.
+func scgLayout(width, height float64) (side, ox, oy float64) {
+	side = math.Max(width, height) * scgScale
+	return side, (width - side) / 2, (height - side) / 2
+}
+
+// scgFlatten converts the segments of a shape into polylines, one per
+// subpath, approximating each curve by n straight pieces.
+func scgFlatten(segs []scgSegment, n int) (polys [][]scgPoint) {
+	var poly []scgPoint
+	for _, seg := range segs {
+		switch seg.op {
+		case 'm':
+			if len(poly) > 0 {
+				polys = append(polys, poly)
+			}
+			poly = []scgPoint{seg.pts[0]}
+		case 'l':
+			poly = append(poly, seg.pts[0])
+		case 'c':
+			p0 := poly[len(poly)-1]
+			p1, p2, p3 := seg.pts[0], seg.pts[1], seg.pts[2]
+			for i := 1; i <= n; i++ {
+				t := float64(i) / float64(n)
+				u := 1 - t
+				a, b, c, d := u*u*u, 3*u*u*t, 3*u*t*t, t*t*t
+				poly = append(poly, scgPoint{
+					a*p0.x + b*p1.x + c*p2.x + d*p3.x,
+					a*p0.y + b*p1.y + c*p2.y + d*p3.y,
+				})
+			}
+		}
+	}
+	if len(poly) > 0 {
+		polys = append(polys, poly)
+	}
+	return
+}
+
+// scgBounds returns the bounding box of the essential figure on a page
+// of the given size, including the line width.
+func scgBounds(width, height float64) (minx, miny, maxx, maxy float64) {
+	side, ox, oy := scgLayout(width, height)
+	hw := scgLineWidth * side / 2
+	minx, miny = math.Inf(1), math.Inf(1)
+	maxx, maxy = math.Inf(-1), math.Inf(-1)
+	for _, shape := range scgShapes {
+		for _, poly := range scgFlatten(shape.segs, scgCurveDiv) {
+			for _, p := range poly {
+				x, y := ox+side*p.x, oy+side*p.y
+				minx, miny = math.Min(minx, x-hw), math.Min(miny, y-hw)
+				maxx, maxy = math.Max(maxx, x+hw), math.Max(maxy, y+hw)
+			}
+		}
+	}
+	return
+}
+
+// scgReal stringifies a number in the style of PDF operands.
+func scgReal(v float64) string {
+	s := strconv.FormatFloat(v, 'f', 4, 64)
+	s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	if s == "-0" {
+		return "0"
+	}
+	return s
+}
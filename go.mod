@@ -5,4 +5,5 @@ go 1.12
 require (
 	github.com/zr-tex8r/scpdf v0.18.0
 	github.com/zr-tex8r/xcolor v0.2.2
+	golang.org/x/text v0.3.6
 )
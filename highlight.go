@@ -0,0 +1,174 @@
+// Copyright (c) 2018-2021 Takayuki YATO (aka. "ZR")
+//   GitHub:   https://github.com/zr-tex8r
+//   Twitter:  @zr_tex8r
+// Distributed under the MIT License.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html"
+	"io/ioutil"
+	"strings"
+)
+
+// schLine is a source line with its tokens and the type it has.
+type schLine struct {
+	src  string
+	lno  int
+	toks []scToken
+	vt   scVType
+}
+
+// schLexFiles reads the input sources again and splits them into tokens,
+// following the includes; the lines turned off by the conditional
+// pragmas are comments as a whole.
+func schLexFiles(j *scJob, psrcs []string) (lines []schLine) {
+	if j.markdown {
+		scePanic(errors.New("highlight mode is not available for Markdown input."))
+	}
+	log := j.log
+	j.log = ioutil.Discard // the progress was shown in the first reading
+	defer func() { j.log = log }()
+	p := j.newParser()
+	p.onLine = func(src string, lno int, toks []scToken, vt scVType) {
+		lines = append(lines, schLine{src, lno, toks, vt})
+	}
+	for _, psrc := range psrcs {
+		j.parseFile(p, psrc)
+	}
+	_, err := p.finish()
+	sceAssert(err)
+	return
+}
+
+// schMultiSource tells whether the lines come from more than one source,
+// as by includes, in which case the runs of each source are headed.
+func schMultiSource(lines []schLine) bool {
+	for _, line := range lines {
+		if line.src != lines[0].src {
+			return true
+		}
+	}
+	return false
+}
+
+//-------- HTML
+
+const schHtmlPrologue = //
+`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="UTF-8">
+<meta name="generator" content="%[1]s">
+<title>%[2]s</title>
+<style>
+pre { line-height: 1.4; }
+.lno { color: #999999; user-select: none; }
+.src { font-weight: bold; }
+.space { background: #F0F0F0; }
+.snowman { color: #FFFFFF; background: #CC0000; font-weight: bold; }
+.duck { color: #806000; background: #FFE680; }
+.ignored { color: #999999; text-decoration: line-through; }
+.sushi { color: #006600; background: #CCEECC; font-weight: bold; }
+.comment { color: #006600; font-style: italic; }
+.essential .lno { color: #CC0000; }
+</style>
+</head>
+<body>
+<h1>%[2]s: <span class="%[3]s">%[3]s</span></h1>
+<p>Legend:
+<span class="snowman">snowman</span>
+<span class="duck">duck</span>
+<span class="ignored">ignored after duck</span>
+<span class="sushi">sushi</span>
+<span class="comment">block comment</span>
+<span class="space"> </span> whitespace</p>
+<pre>
+`
+
+const schHtmlEpilogue = //
+`</pre>
+</body>
+</html>
+`
+
+func (j *scJob) makeHighlightHtml(lines []schLine) string {
+	vt := j.value.vtype
+	esc := html.EscapeString
+	buf := new(bytes.Buffer)
+	fmt.Fprintf(buf, schHtmlPrologue, esc(progName+" "+version),
+		esc(ordInPath(j.inFile)), vt)
+	multi := schMultiSource(lines)
+	for i, line := range lines {
+		if multi && (i == 0 || lines[i-1].src != line.src) {
+			fmt.Fprintf(buf, "<span class=\"src\">%s</span>\n", esc(ordInPath(line.src)))
+		}
+		fmt.Fprintf(buf, `<span class="%s"><span class="lno">%4d </span>`, line.vt, line.lno)
+		for _, tok := range line.toks {
+			fmt.Fprintf(buf, `<span class="%s" title="%s">%s</span>`,
+				tok.kind, tok.kind, esc(tok.text))
+		}
+		buf.WriteString("</span>\n")
+	}
+	buf.WriteString(schHtmlEpilogue)
+	return buf.String()
+}
+
+//-------- ANSI
+
+var schAnsiStyle = map[scTokKind]string{
+	scTokSpace:   "",
+	scTokSnowman: "\x1b[1;97;41m",
+	scTokDuck:    "\x1b[30;43m",
+	scTokIgnored: "\x1b[2;9m",
+	scTokSushi:   "\x1b[1;32m",
+	scTokComment: "\x1b[3;32m",
+}
+
+func (j *scJob) makeHighlightAnsi(lines []schLine) string {
+	vt, depth := j.value.vtype, j.termDepth
+	buf := new(bytes.Buffer)
+	fmt.Fprintf(buf, "%s: %s\n", ordInPath(j.inFile), vt)
+	multi := schMultiSource(lines)
+	for i, line := range lines {
+		if multi && (i == 0 || lines[i-1].src != line.src) {
+			fmt.Fprintf(buf, "-- %s\n", ordInPath(line.src))
+		}
+		mark := ' '
+		if line.vt == scEssential {
+			mark = '*'
+		}
+		fmt.Fprintf(buf, "%4d%c ", line.lno, mark)
+		for _, tok := range line.toks {
+			text := tok.text
+			if depth == sctTermNoColor || tok.kind == scTokSpace {
+				if tok.kind == scTokSpace && depth != sctTermNoColor {
+					text = strings.Replace(text, "\t", "→", -1)
+				}
+				buf.WriteString(text)
+				continue
+			}
+			buf.WriteString(schAnsiStyle[tok.kind] + text + "\x1b[0m")
+		}
+		buf.WriteString("\n")
+	}
+	return buf.String()
+}
+
+// makeHighlightText makes the output of highlight mode in the format
+// given by --highlight-format.
+func (j *scJob) makeHighlightText() string {
+	lines := schLexFiles(j, j.sources)
+	switch j.highlight {
+	case "", "html":
+		return j.makeHighlightHtml(lines)
+	case "ansi":
+		return j.makeHighlightAnsi(lines)
+	default:
+		scePanic(fmt.Errorf("unknown highlight format '%s'", j.highlight))
+	}
+	return ""
+}
@@ -0,0 +1,380 @@
+// Copyright (c) 2018-2021 Takayuki YATO (aka. "ZR")
+//   GitHub:   https://github.com/zr-tex8r
+//   Twitter:  @zr_tex8r
+// Distributed under the MIT License.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image/color"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/zr-tex8r/scpdf"
+	"github.com/zr-tex8r/xcolor"
+)
+
+// scJob holds the settings and the state of one build. Each build has
+// its own job, so that builds can run side by side.
+type scJob struct {
+	sources   []string          // the input sources, in order
+	evalTexts map[string]string // texts of the synthetic sources
+	inFile    string            // the main input
+	outFile   string
+	depFile   string // where the dependencies are written, if any
+
+	textMode        string
+	mufflerVal      string
+	mufflerColor    color.Color
+	markdown        bool
+	dpi             int64
+	transparent     bool
+	template        string
+	validateXml     bool
+	highlight       string
+	typeCheckOnly   bool
+	byteComp        bool
+	config          string
+	noDefaultConfig bool
+	termDepth       sctTermDepth
+	dumpDeps        string // the format of the dependency graph, if any
+	defines         map[string]string
+	lexicon         string                 // the name of the lexical profile
+	lexTables       map[string]*scLexTable // the profiles, read lazily
+	inputEncoding   string
+	noInclude       bool // include pragmas are ordinary comments
+
+	value scValue  // the document, after parsing
+	deps  []string // the files read, in the order of first access
+	// the include pragmas and the type of each source
+	includes []scInclude
+	srcTypes map[string]scVType
+
+	log io.Writer // receives the progress messages
+	out io.Writer // receives the output of term mode and the like
+}
+
+// newJob makes a job with the default settings, which reads nothing
+// and prints nothing.
+func newJob() *scJob {
+	j := &scJob{
+		evalTexts: make(map[string]string),
+		dpi:       dfltDpi,
+		log:       ioutil.Discard,
+		out:       ioutil.Discard,
+	}
+	sceAssert(j.setMuffler(dfltMuffler))
+	return j
+}
+
+func (j *scJob) setMuffler(val string) error {
+	col, err := xcolor.GoColor(val)
+	if err != nil {
+		return err
+	}
+	j.mufflerVal, j.mufflerColor = val, col
+	return nil
+}
+
+func (j *scJob) setTextMode(val string) error {
+	vals := strings.Split(val, ",")
+	if len(vals) > 1 {
+		return errors.New("--text-mode can have only one value.")
+	}
+	j.textMode = strings.TrimSpace(vals[0])
+	return nil
+}
+
+// check examines the combination of the settings.
+func (j *scJob) check() error {
+	if j.template != "" && j.textMode != "html" && j.textMode != "xml" {
+		return errors.New("--template is available only in html or xml mode.")
+	}
+	if j.template != "" && j.validateXml {
+		return errors.New("--validate-xml is not available with --template.")
+	}
+	if j.dpi <= 0 || j.dpi > maxDpi {
+		return fmt.Errorf("illegal dpi value (%d); it must be 1 to %d.", j.dpi, maxDpi)
+	}
+	if _, ok := scLookupEncoding(j.inputEncoding); !ok {
+		return fmt.Errorf("unknown input encoding '%s'.", j.inputEncoding)
+	}
+	if j.highlight != "" && j.highlight != "html" && j.highlight != "ansi" {
+		return fmt.Errorf("unknown highlight format '%s'.", j.highlight)
+	}
+	if j.dumpDeps != "" && j.dumpDeps != "dot" {
+		return fmt.Errorf("unknown --dump-deps format '%s'.", j.dumpDeps)
+	}
+	return nil
+}
+
+func (j *scJob) isEvalSource(psrc string) bool {
+	_, ok := j.evalTexts[psrc]
+	return ok
+}
+
+// noteDep records a file read during the build.
+func (j *scJob) noteDep(path string) {
+	for _, p := range j.deps {
+		if p == path {
+			return
+		}
+	}
+	j.deps = append(j.deps, path)
+}
+
+//-------- the pipeline
+
+// compile processes the input sources and writes the output.
+func (j *scJob) compile() {
+	fmt.Fprintf(j.log, " ---- ---- ---- ----\n")
+	fmt.Fprintf(j.log, "  target file: '%s'\n", ordPath(j.outFile))
+	aux := changeExt(j.outFile, ".scsatysfi-aux")
+	fmt.Fprintf(j.log, "  dump file: '%s' (won't be created)\n", ordPath(aux))
+
+	j.typeCheck()
+	if j.typeCheckOnly {
+		return
+	}
+
+	fmt.Fprintf(j.log, " ---- ---- ---- ----\n")
+	fmt.Fprintf(j.log, "  evaluating texts ...\n")
+
+	if isShowFont {
+		fmt.Fprintf(j.log, "  all the available fonts:\n")
+		fmt.Fprintf(j.log, "  ...oops, there's no such inessential concept!\n")
+	}
+
+	fmt.Fprintf(j.log, "  evaluation done.\n")
+
+	if j.byteComp {
+		j.byteExec(byteCompile(j.value))
+	} else {
+		j.writeOutput(j.outFile)
+	}
+	if j.depFile != "" && !j.isStdoutMode() {
+		j.writeDepFile(j.depFile)
+	}
+}
+
+// typeCheck parses the input sources and checks the document.
+func (j *scJob) typeCheck() {
+	j.value = j.parseFiles(j.sources)
+	j.readFile(j.inFile, j.value)
+}
+
+// render makes the output of the text mode.
+func (j *scJob) render() []byte {
+	switch j.textMode {
+	case "":
+		return j.makePdfBytes()
+	case "plain":
+		return []byte(j.makePlainText())
+	case "html":
+		return []byte(j.makeHtmlText())
+	case "xml":
+		text := j.makeXmlText()
+		if j.validateXml {
+			j.checkXmlText(text)
+		}
+		return []byte(text)
+	case "png":
+		return j.makePngBytes()
+	case "ps":
+		return []byte(j.makePsText(false))
+	case "eps":
+		return []byte(j.makePsText(true))
+	case "epub":
+		return j.makeEpubBytes()
+	case "odt":
+		return j.makeOdtBytes()
+	case "docx":
+		return j.makeDocxBytes()
+	case "latex":
+		return []byte(j.makeLatexText())
+	case "json":
+		return []byte(j.makeJsonText())
+	case "term":
+		return []byte(j.makeTermText())
+	case "highlight":
+		return []byte(j.makeHighlightText())
+	default:
+		scePanic(fmt.Errorf("unknown text mode value '%s'", j.textMode))
+	}
+	return nil
+}
+
+// isStdoutMode tells whether the output goes to the standard output
+// instead of the output file.
+func (j *scJob) isStdoutMode() bool {
+	return j.textMode == "term" || (j.textMode == "highlight" && j.highlight == "ansi")
+}
+
+// mediaType returns the media type of the output.
+func (j *scJob) mediaType() string {
+	if j.textMode == "highlight" && j.highlight == "ansi" {
+		return textModeMediaType["plain"]
+	}
+	return textModeMediaType[j.textMode]
+}
+
+func (j *scJob) writeOutput(pdst string) {
+	data := j.render()
+	if j.isStdoutMode() {
+		fmt.Fprintf(j.log, " ---- ---- ---- ----\n")
+		_, err := j.out.Write(data)
+		sceAssert(err)
+		return
+	}
+	writeBytes(pdst, data)
+
+	fmt.Fprintf(j.log, " ---- ---- ---- ----\n")
+	fmt.Fprintf(j.log, "  output written on '%s'.\n", ordPath(pdst))
+}
+
+func (j *scJob) checkXmlText(text string) {
+	fmt.Fprintf(j.log, " ---- ---- ---- ----\n")
+	fmt.Fprintf(j.log, "  validating XML output ...\n")
+	if err := scxValidate(scxSchema, strings.NewReader(text)); err != nil {
+		scePanic(sceXmlInvalidError(err))
+	}
+	fmt.Fprintf(j.log, "  validation passed.\n")
+}
+
+func writeBytes(pdst string, data []byte) {
+	wdst, err := os.Create(pdst)
+	sceAssert(err)
+	defer wdst.Close()
+
+	_, err = wdst.Write(data)
+	sceAssert(err)
+}
+
+func (j *scJob) makePdfBytes() []byte {
+	fmt.Fprintf(j.log, " ---- ---- ---- ----\n")
+	fmt.Fprintf(j.log, "  writing pages ...\n")
+
+	doc := new(scpdf.Doc)
+	doc.SetDocInfo(map[string]string{
+		"title":   docTitle,
+		"creator": "scSATySFi",
+	})
+	doc.AddPage(j.mufflerColor)
+	buf := new(bytes.Buffer)
+	_, err := doc.WriteTo(buf)
+	sceAssert(err)
+	return buf.Bytes()
+}
+
+func (j *scJob) readFile(psrc string, value scValue) {
+	fmt.Fprintf(j.log, " ---- ---- ---- ----\n")
+	fmt.Fprintf(j.log, "  type checking '%s' ...\n", ordInPath(psrc))
+	fmt.Fprintf(j.log, "  type check passed. (%s)\n", value.vtype)
+
+	// highlight mode explains why a file is not essential
+	if !j.typeCheckOnly && value.vtype != scEssential && j.textMode != "highlight" {
+		scePanic(sceNonDocError(psrc, value.vtype))
+	}
+}
+
+// openInFile opens an input source, decoded from the input encoding.
+// Synthetic sources are in UTF-8.
+func (j *scJob) openInFile(psrc string) (io.ReadCloser, error) {
+	var data []byte
+	text, ok := j.evalTexts[psrc]
+	enc := "utf-8"
+	if ok {
+		data = []byte(text)
+	} else {
+		var err error
+		if data, err = ioutil.ReadFile(psrc); err != nil {
+			return nil, err
+		}
+		enc = j.inputEncoding
+	}
+	data, err := scDecode(psrc, data, enc)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.NopCloser(bytes.NewReader(data)), nil
+}
+
+// newParser makes a parser with the settings of the job: the includes,
+// the defines and the lexical profiles.
+func (j *scJob) newParser() *scParser {
+	p := newScParser()
+	if !j.noInclude {
+		p.include = j.findInclude
+	}
+	p.cond = newScCond(j.defines)
+	p.lex, p.lexTable = j.mainLexTable(), j.lexTable
+	p.open = j.openInFile
+	return p
+}
+
+// parseFiles parses the input sources as one document.
+func (j *scJob) parseFiles(psrcs []string) (value scValue) {
+	p := j.newParser()
+	j.srcTypes = p.srcTypes
+	for _, psrc := range psrcs {
+		value = j.parseFile(p, psrc)
+		if j.markdown {
+			j.srcTypes[psrc] = value.vtype
+		}
+	}
+	j.includes = p.includes
+	if !j.markdown {
+		var err error
+		value, err = p.finish()
+		sceAssert(err)
+	}
+	return
+}
+
+// findInclude finds the file included from psrc, first relative to
+// psrc (or the current directory for synthetic sources), and then
+// along the configuration search path.
+func (j *scJob) findInclude(psrc, name string) (string, error) {
+	cands := []string{name}
+	if !filepath.IsAbs(name) {
+		cands[0] = filepath.Join(filepath.Dir(psrc), name)
+		if j.isEvalSource(psrc) {
+			cands[0] = name
+		}
+		for _, dir := range cfgSearchPath(j) {
+			cands = append(cands, filepath.Join(dir, name))
+		}
+	}
+	for _, path := range cands {
+		if cfgFileExists(path) {
+			fmt.Fprintf(j.log, "  including '%s' ...\n", ordInPath(path))
+			j.noteDep(path)
+			return path, nil
+		}
+	}
+	return "", sceNotFoundError(name, cands)
+}
+
+func (j *scJob) parseFile(p *scParser, psrc string) (value scValue) {
+	fmt.Fprintf(j.log, "  parsing '%s' ...\n", ordInPath(psrc))
+	if !j.isEvalSource(psrc) {
+		j.noteDep(psrc)
+	}
+
+	rsrc, err := j.openInFile(psrc)
+	sceAssert(err)
+	defer rsrc.Close()
+	if j.markdown {
+		value, err = scParseMarkdown(rsrc)
+	} else {
+		err = p.parse(psrc, rsrc)
+	}
+	sceAssert(err)
+	return
+}
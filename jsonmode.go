@@ -0,0 +1,95 @@
+// Copyright (c) 2018-2021 Takayuki YATO (aka. "ZR")
+//   GitHub:   https://github.com/zr-tex8r
+//   Twitter:  @zr_tex8r
+// Distributed under the MIT License.
+
+package main
+
+import (
+	"encoding/json"
+
+	"github.com/zr-tex8r/xcolor"
+)
+
+// The JSON Schema for the output, shipped as schema/document.schema.json.
+const scjSchemaId = "https://raw.githubusercontent.com/zr-tex8r/scsatysfi/master/schema/document.schema.json"
+
+type scjDocument struct {
+	Schema    string            `json:"$schema"`
+	Type      string            `json:"type"`
+	Sources   []scjSource       `json:"sources"`
+	Snowmen   scjSnowmen        `json:"snowmen"`
+	Muffler   scjMuffler        `json:"muffler"`
+	Metadata  map[string]string `json:"metadata"`
+	Generator scjGenerator      `json:"generator"`
+}
+
+type scjSource struct {
+	Name     string `json:"name"`
+	Path     string `json:"path"`
+	Markdown bool   `json:"markdown"`
+}
+
+type scjSnowmen struct {
+	Count     int           `json:"count"`
+	Positions []scjPosition `json:"positions"`
+}
+
+type scjPosition struct {
+	Source string `json:"source"`
+	Line   int    `json:"line"`
+	Column int    `json:"column"`
+}
+
+type scjMuffler struct {
+	Spec  string    `json:"spec"`
+	Model string    `json:"model"`
+	Gray  []float64 `json:"gray"`
+	Rgb   []float64 `json:"rgb"`
+	Cmyk  []float64 `json:"cmyk"`
+	Html  string    `json:"html"`
+}
+
+type scjGenerator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+func (j *scJob) makeJsonText() string {
+	col, err := xcolor.Parse(j.mufflerVal)
+	if err != nil {
+		scePanic(err)
+	}
+	doc := scjDocument{
+		Schema: scjSchemaId,
+		Type:   j.value.vtype.String(),
+		Snowmen: scjSnowmen{
+			Count:     len(j.value.snowmen),
+			Positions: []scjPosition{},
+		},
+		Muffler: scjMuffler{
+			Spec:  j.mufflerVal,
+			Model: col.Model().String(),
+			Gray:  col.Convert(xcolor.Gray).Params(),
+			Rgb:   col.Convert(xcolor.Rgb).Params(),
+			Cmyk:  col.Convert(xcolor.Cmyk).Params(),
+			Html:  col.HtmlCode(),
+		},
+		Metadata: map[string]string{
+			"title":   docTitle,
+			"creator": progName,
+		},
+		Generator: scjGenerator{progName, version},
+	}
+	for _, psrc := range j.sources {
+		doc.Sources = append(doc.Sources,
+			scjSource{ordInPath(psrc), fullInPath(psrc), j.markdown})
+	}
+	for _, p := range j.value.snowmen {
+		doc.Snowmen.Positions = append(doc.Snowmen.Positions,
+			scjPosition{ordInPath(p.src), p.line, p.col})
+	}
+	bs, err := json.MarshalIndent(doc, "", "  ")
+	sceAssert(err)
+	return string(bs) + "\n"
+}
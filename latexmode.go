@@ -0,0 +1,86 @@
+// Copyright (c) 2018-2021 Takayuki YATO (aka. "ZR")
+//   GitHub:   https://github.com/zr-tex8r
+//   Twitter:  @zr_tex8r
+// Distributed under the MIT License.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/zr-tex8r/xcolor"
+)
+
+// sclColorDef returns the \definecolor command for a muffler color,
+// which keeps the color model and the parameters of the xcolor expression.
+func sclColorDef(name, val string) string {
+	col, err := xcolor.Parse(val)
+	if err != nil {
+		scePanic(err)
+	}
+	ps := col.Params()
+	t := make([]string, len(ps))
+	for i, p := range ps {
+		t[i] = strconv.FormatFloat(p, 'f', -1, 64)
+	}
+	return fmt.Sprintf("\\definecolor{%s}{%s}{%s}",
+		name, col.Model(), strings.Join(t, ","))
+}
+
+// sclPathCode returns the TikZ path for a shape.
+func sclPathCode(shape scgShape) string {
+	pt := func(p scgPoint) string {
+		return "(" + scgReal(p.x) + "," + scgReal(p.y) + ")"
+	}
+	buf := new(bytes.Buffer)
+	for i, seg := range shape.segs {
+		switch seg.op {
+		case 'm':
+			if i > 0 {
+				buf.WriteString("\n  ")
+			}
+			buf.WriteString(pt(seg.pts[0]))
+		case 'l':
+			buf.WriteString(" -- " + pt(seg.pts[0]))
+		case 'c':
+			fmt.Fprintf(buf, "\n  .. controls %s and %s .. %s",
+				pt(seg.pts[0]), pt(seg.pts[1]), pt(seg.pts[2]))
+		}
+	}
+	if shape.closed {
+		buf.WriteString(" -- cycle")
+	}
+	return buf.String()
+}
+
+func (j *scJob) makeLatexText() string {
+	side, _, _ := scgLayout(scgPageWidth, scgPageHeight)
+	buf := new(bytes.Buffer)
+	fmt.Fprintf(buf, "%% Generated by %s %s\n", progName, version)
+	buf.WriteString("\\documentclass[tikz]{standalone}\n")
+	fmt.Fprintf(buf, "%% muffler: %s\n", j.mufflerVal)
+	buf.WriteString(sclColorDef("muffler", j.mufflerVal) + "\n")
+	buf.WriteString("\\begin{document}\n")
+	fmt.Fprintf(buf, "\\begin{tikzpicture}[x=%sbp,y=%sbp,\n", scgReal(side), scgReal(side))
+	fmt.Fprintf(buf, "  line width=%sbp,line join=round,line cap=round]\n",
+		scgReal(scgLineWidth*side))
+	for _, shape := range scgShapes {
+		col := "black"
+		if shape.muffler {
+			col = "muffler"
+		}
+		cmd := "\\draw"
+		if shape.fill && shape.stroke {
+			cmd = "\\filldraw"
+		} else if shape.fill {
+			cmd = "\\fill"
+		}
+		fmt.Fprintf(buf, "%s[%s] %s;\n", cmd, col, sclPathCode(shape))
+	}
+	buf.WriteString("\\end{tikzpicture}\n")
+	buf.WriteString("\\end{document}\n")
+	return buf.String()
+}
@@ -0,0 +1,190 @@
+// Copyright (c) 2018-2021 Takayuki YATO (aka. "ZR")
+//   GitHub:   https://github.com/zr-tex8r
+//   Twitter:  @zr_tex8r
+// Distributed under the MIT License.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// scLexTable is a lexical profile, which tells the characters that are
+// snowmen, ducks and sushi. The other characters, except for spaces,
+// are invalid.
+type scLexTable struct {
+	name  string
+	kinds map[rune]scTokKind // scTokSnowman, scTokDuck or scTokSushi
+}
+
+func newScLexTable(name, snowmen, ducks, sushi string) *scLexTable {
+	lt := &scLexTable{name: name, kinds: make(map[rune]scTokKind)}
+	for _, r := range snowmen {
+		lt.kinds[r] = scTokSnowman
+	}
+	for _, r := range ducks {
+		lt.kinds[r] = scTokDuck
+	}
+	for _, r := range sushi {
+		lt.kinds[r] = scTokSushi
+	}
+	return lt
+}
+
+// The built-in profiles; "emoji" has only the emoji characters.
+var scLexBuiltins = map[string]*scLexTable{
+	"default": newScLexTable("default", "8\u2603\u26C4\u26C7", "2\U0001F986", "@\U0001F363"),
+	"emoji":   newScLexTable("emoji", "\u2603\u26C4", "\U0001F986", "\U0001F363"),
+	"ascii":   newScLexTable("ascii", "8", "2", "@"),
+}
+
+var scDefaultLex = scLexBuiltins["default"]
+
+// kind returns the kind of a character, which is scTokSpace for spaces;
+// ok is false for invalid characters.
+func (lt *scLexTable) kind(r rune) (kind scTokKind, ok bool) {
+	if r == ' ' || r == '\t' {
+		return scTokSpace, true
+	}
+	kind, ok = lt.kinds[r]
+	return
+}
+
+// classify returns the kind of a grapheme cluster, which is that of
+// its first character if the rest are variation selectors or skin tone
+// modifiers, as in U+2603 U+FE0F. Other sequences, such as those joined
+// by ZWJ, and spaces with them have no kind.
+func (lt *scLexTable) classify(c string) (kind scTokKind, ok bool) {
+	r, n := utf8.DecodeRuneInString(c)
+	for _, m := range c[n:] {
+		if !unicode.Is(unicode.Variation_Selector, m) && !('\U0001F3FB' <= m && m <= '\U0001F3FF') {
+			return
+		}
+	}
+	if kind, ok = lt.kind(r); kind == scTokSpace && n < len(c) {
+		ok = false
+	}
+	return
+}
+
+// The file of the user profiles, found along the configuration search
+// path:
+//
+//	[profile.wintry]
+//	base = "default"                 # adds to a built-in profile
+//	snowman = ["U+1F328", "U+2744"]
+//
+//	[profile.strict]                 # without base, the sets are whole
+//	snowman = ["U+2603"]
+//	duck = ["\U0001F986"]
+//	sushi = ["\U0001F363"]
+//
+// A character is given as itself or as "U+XXXX".
+const scLexiconName = "lexicon.toml"
+
+var scLexSetKind = map[string]scTokKind{
+	"snowman": scTokSnowman, "duck": scTokDuck, "sushi": scTokSushi,
+}
+
+// lexTable returns the profile of the name, or nil if there is no
+// such one. The user profiles are read on the first call.
+func (j *scJob) lexTable(name string) *scLexTable {
+	if j.lexTables == nil {
+		j.lexTables = make(map[string]*scLexTable)
+		for n, lt := range scLexBuiltins {
+			j.lexTables[n] = lt
+		}
+		if path, err := cfgFindFile(j, scLexiconName, ""); err == nil {
+			readLexicon(path, j.lexTables)
+		}
+	}
+	return j.lexTables[name]
+}
+
+// mainLexTable returns the profile given by --lexicon.
+func (j *scJob) mainLexTable() *scLexTable {
+	name := j.lexicon
+	if name == "" {
+		name = scDefaultLex.name
+	}
+	lt := j.lexTable(name)
+	if lt == nil {
+		scePanic(fmt.Errorf("unknown lexical profile '%s'.", name))
+	}
+	return lt
+}
+
+// readLexicon adds the profiles in the file to tables.
+func readLexicon(path string, tables map[string]*scLexTable) {
+	rsrc, err := os.Open(path)
+	sceAssert(err)
+	defer rsrc.Close()
+	tbl, err := tmlParse(path, rsrc)
+	sceAssert(err)
+
+	for key := range tbl {
+		if key != "profile" {
+			scePanic(sceConfigFileError(path, fmt.Sprintf("unknown key '%s'", key)))
+		}
+	}
+	profs, _ := tbl["profile"].(tmlTable)
+	var names []string
+	for name := range profs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		prof, ok := profs[name].(tmlTable)
+		if !ok {
+			scePanic(sceConfigFileError(path, fmt.Sprintf("profile '%s' must be a table", name)))
+		}
+		what := "profile '" + name + "'"
+		lt := &scLexTable{name: name, kinds: make(map[rune]scTokKind)}
+		if base, ok := manifestString(path, what, prof, "base"); ok {
+			blt, ok := scLexBuiltins[base]
+			if !ok {
+				scePanic(sceConfigFileError(path, fmt.Sprintf("%s: unknown base '%s'", what, base)))
+			}
+			for r, k := range blt.kinds {
+				lt.kinds[r] = k
+			}
+		}
+		for key := range prof {
+			if _, ok := scLexSetKind[key]; !ok && key != "base" {
+				scePanic(sceConfigFileError(path, fmt.Sprintf("%s: unknown key '%s'", what, key)))
+			}
+		}
+		for _, set := range []string{"snowman", "duck", "sushi"} {
+			for _, spec := range manifestStrings(path, what, prof, set) {
+				r, ok := scLexParseChar(spec)
+				if !ok || r == ' ' || r == '\t' {
+					scePanic(sceConfigFileError(path, fmt.Sprintf("%s: bad character '%s' in %s", what, spec, set)))
+				}
+				if k, ok := lt.kinds[r]; ok && k != scLexSetKind[set] {
+					scePanic(sceConfigFileError(path, fmt.Sprintf("%s: U+%04X is both %s and %s", what, r, k, set)))
+				}
+				lt.kinds[r] = scLexSetKind[set]
+			}
+		}
+		tables[name] = lt
+	}
+}
+
+// scLexParseChar reads a character given as itself or as "U+XXXX".
+func scLexParseChar(spec string) (rune, bool) {
+	if strings.HasPrefix(spec, "U+") {
+		v, err := strconv.ParseUint(spec[2:], 16, 32)
+		if err != nil || !utf8.ValidRune(rune(v)) {
+			return 0, false
+		}
+		return rune(v), true
+	}
+	r, size := utf8.DecodeRuneInString(spec)
+	return r, r != utf8.RuneError && size == len(spec)
+}
@@ -6,17 +6,9 @@
 package main
 
 import (
-	"bytes"
 	"errors"
 	"fmt"
-	"image/color"
-	"io"
-	"io/ioutil"
 	"os"
-	"strings"
-
-	"github.com/zr-tex8r/scpdf"
-	"github.com/zr-tex8r/xcolor"
 )
 
 const (
@@ -26,8 +18,50 @@ const (
 
 const dfltMuffler = "cmyk:red,1"
 
+// Usual file extensions for the outputs of the text modes.
+var textModeExt = map[string]string{
+	"":          ".pdf",
+	"plain":     ".txt",
+	"html":      ".html",
+	"xml":       ".xml",
+	"png":       ".png",
+	"ps":        ".ps",
+	"eps":       ".eps",
+	"json":      ".json",
+	"latex":     ".tex",
+	"epub":      ".epub",
+	"odt":       ".odt",
+	"docx":      ".docx",
+	"highlight": ".html",
+}
+
+// Media types of the outputs, by text mode.
+var textModeMediaType = map[string]string{
+	"":          "application/pdf",
+	"plain":     "text/plain; charset=utf-8",
+	"html":      "text/html; charset=utf-8",
+	"xml":       "application/xml",
+	"png":       "image/png",
+	"ps":        "application/postscript",
+	"eps":       "application/postscript",
+	"json":      "application/json",
+	"latex":     "application/x-tex",
+	"epub":      "application/epub+zip",
+	"odt":       "application/vnd.oasis.opendocument.text",
+	"docx":      "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+	"term":      "text/plain; charset=utf-8",
+	"highlight": "text/html; charset=utf-8",
+}
+
+const docTitle = "\u2603"
+
+// The language of the document content; the essential content has no
+// linguistic content (ISO 639-2 "zxx").
+const docLang = "zxx"
+
 var (
 	inFile              string
+	inSources           []string
 	outFile             string
 	fullPath            bool
 	mufflerVal          string
@@ -38,16 +72,24 @@ var (
 	debugShowOverfull   bool
 	typeCheckOnly       bool
 	byteComp            bool
-	mufflerColor        color.Color
 	textModeVal         string
-	textMode            string
 	markdownVal         string
-	isMarkdown          bool
-	evalVal             string
 	isShowFont          bool
 	config              string
 	noDefaultConfig     bool
 	pageNumberLimit     int64
+	dpiVal              int64
+	isTransparent       bool
+	templateVal         string
+	validateXml         bool
+	highlightVal        string
+	watchMode           bool
+	rpcMode             bool
+	depFileVal          string
+	dumpDepsVal         string
+	defineVals          []string
+	lexiconVal          string
+	inputEncodingVal    string
 )
 
 func showVersion(string, string) error {
@@ -85,154 +127,194 @@ var argSpecList = []argInfo{
 	argInfo{"--no-default-config", argBool, argSetBool(&noDefaultConfig), " Does not use default configuration search path"},
 	// How does it work?
 	argInfo{"--page-number-limit", argInt, argSetInt(&pageNumberLimit), " Set the page number limit (default: 10000)"},
-	argInfo{"--eval", argStr, argSetStr(&evalVal), " Give one line of source text"},
+	argInfo{"--eval", argStr, addEval, " Give one line of source text (repeatable)"},
 	argInfo{"--muffler", argStr, argSetStr(&mufflerVal), " Specify muffler color"},
+	argInfo{"--dpi", argInt, argSetInt(&dpiVal), " Set the resolution for png mode, up to 600 (default: 72)"},
+	argInfo{"--template", argStr, argSetStr(&templateVal), " Use a template file for html or xml mode"},
+	argInfo{"--validate-xml", argBool, argSetBool(&validateXml), " Validates the output of xml mode against the schema"},
+	argInfo{"--highlight-format", argStr, argSetStr(&highlightVal), " Set the format of highlight mode (html or ansi)"},
+	argInfo{"--watch", argBool, argSetBool(&watchMode), " Rebuilds the output whenever the input changes"},
+	argInfo{"-M", argStr, argSetStr(&depFileVal), " Writes the dependencies of the output as a Makefile fragment"},
+	argInfo{"--dep-file", argStr, argSetStr(&depFileVal), " Writes the dependencies of the output as a Makefile fragment"},
+	argInfo{"--define", argStr, argAddStr(&defineVals), " Define a name for the conditional pragmas (NAME or NAME=VALUE, repeatable)"},
+	argInfo{"--lexicon", argStr, argSetStr(&lexiconVal), " Specify the lexical profile (default: default)"},
+	argInfo{"--input-encoding", argStr, argSetStr(&inputEncodingVal), " Specify the encoding of input files (utf-8, shift_jis or euc-jp)"},
+	argInfo{"--dump-deps", argStr, argSetStr(&dumpDepsVal), " Prints the include and dependency graph (format: dot)"},
+	argInfo{"--rpc", argBool, argSetBool(&rpcMode), " Speaks JSON-RPC 2.0 on stdin and stdout"},
+	argInfo{"--transparent", argBool, argSetBool(&isTransparent), " Leaves the background transparent in png mode"},
 }
 
-func readArg() {
-	argParse(argSpecList, func(arg string) error {
-		inFile = arg
-		return nil
-	})
-	if inFile == "" && evalVal == "" {
-		scePanic(errors.New("no input file designation."))
-	} else if inFile == "" && evalVal != "" {
-		inFile = "(eval)"
-	} else if inFile != "" && evalVal != "" {
-		scePanic(errors.New("both input file and --eval are given."))
-	}
-	if outFile == "" {
-		if evalVal == "" {
-			outFile = changeExt(inFile, ".pdf")
-		} else {
-			outFile = "output.pdf"
+// The variables set by argSpecList, which saveCliOptions saves.
+var cliOptionVars = []interface{}{
+	&outFile, &fullPath, &mufflerVal, &debugShowBbox, &debugShowSpace,
+	&debugShowBlockBbox, &debugShowBlockSpace, &debugShowOverfull,
+	&typeCheckOnly, &byteComp, &textModeVal, &markdownVal, &isShowFont,
+	&config, &noDefaultConfig, &pageNumberLimit, &dpiVal, &isTransparent,
+	&templateVal, &validateXml, &highlightVal, &watchMode, &rpcMode,
+	&depFileVal, &dumpDepsVal, &defineVals, &lexiconVal,
+	&inputEncodingVal, &inSources,
+}
+
+// saveCliOptions saves the current values of the options and returns
+// the function that restores them, so that another set of options can
+// be parsed in between.
+func saveCliOptions() (restore func()) {
+	saved := make([]interface{}, len(cliOptionVars))
+	for i, vp := range cliOptionVars {
+		switch vp := vp.(type) {
+		case *string:
+			saved[i] = *vp
+		case *bool:
+			saved[i] = *vp
+		case *int64:
+			saved[i] = *vp
+		case *[]string:
+			saved[i] = append([]string(nil), *vp...)
 		}
 	}
-	if mufflerVal == "" {
-		mufflerVal = dfltMuffler
+	texts := make(map[string]string)
+	for name, text := range evalTexts {
+		texts[name] = text
 	}
-	if textModeVal != "" {
-		vals := strings.Split(textModeVal, ",")
-		if len(vals) > 1 {
-			scePanic(errors.New("--text-mode can have only one value."))
+	return func() {
+		for i, vp := range cliOptionVars {
+			switch vp := vp.(type) {
+			case *string:
+				*vp = saved[i].(string)
+			case *bool:
+				*vp = saved[i].(bool)
+			case *int64:
+				*vp = saved[i].(int64)
+			case *[]string:
+				*vp = append([]string(nil), saved[i].([]string)...)
+			}
+		}
+		evalTexts = make(map[string]string)
+		for name, text := range texts {
+			evalTexts[name] = text
 		}
-		textMode = strings.TrimSpace(vals[0])
-	}
-	if markdownVal != "" {
-		isMarkdown = true
-	}
-	var err error
-	if mufflerColor, err = xcolor.GoColor(mufflerVal); err != nil {
-		scePanic(err)
 	}
 }
 
-func main() {
-	readArg()
+// Texts given by --eval, keyed by their synthetic source names.
+var evalTexts = make(map[string]string)
 
-	fmt.Printf(" ---- ---- ---- ----\n")
-	fmt.Printf("  target file: '%s'\n", ordPath(outFile))
-	aux := changeExt(outFile, ".scsatysfi-aux")
-	fmt.Printf("  dump file: '%s' (won't be created)\n", ordPath(aux))
-
-	value := parseFile(inFile)
+func addEval(arg, opt string) error {
+	name := fmt.Sprintf("(eval#%d)", len(evalTexts)+1)
+	evalTexts[name] = arg
+	inSources = append(inSources, name)
+	return nil
+}
 
-	readFile(inFile, value)
-	if typeCheckOnly {
+func readArg() {
+	argParse(argSpecList, func(arg string) error {
+		inSources = append(inSources, arg)
+		return nil
+	})
+	if rpcMode {
+		if len(inSources) > 0 {
+			scePanic(errors.New("--rpc takes no input file or --eval."))
+		}
 		return
 	}
-
-	fmt.Printf(" ---- ---- ---- ----\n")
-	fmt.Printf("  evaluating texts ...\n")
-
-	if isShowFont {
-		fmt.Printf("  all the available fonts:\n")
-		fmt.Printf("  ...oops, there's no such inessential concept!\n")
-	}
-
-	fmt.Printf("  evaluation done.\n")
-
-	if byteComp {
-		byteExec(byteCompile(value))
-	} else {
-		writeOutput(outFile)
+	if len(inSources) == 0 {
+		scePanic(errors.New("no input file designation."))
 	}
+	resolveInput(".pdf")
 }
 
-func writeOutput(pdst string) {
-	switch textMode {
-	case "":
-		writePdf(pdst)
-	case "plain":
-		writeText(pdst, makePlainText())
-	case "html":
-		writeText(pdst, makeHtmlText())
-	case "xml":
-		writeText(pdst, makeXmlText())
-	default:
-		scePanic(fmt.Errorf("unknown text mode value '%s'", textMode))
+// resolveInput decides the main input, which is the first file if any,
+// and the output file if not given, with the extension ext.
+func resolveInput(ext string) {
+	inFile = inSources[0]
+	for _, psrc := range inSources {
+		if _, ok := evalTexts[psrc]; !ok {
+			inFile = psrc
+			break
+		}
+	}
+	if outFile == "" {
+		if _, ok := evalTexts[inFile]; !ok {
+			outFile = changeExt(inFile, ext)
+		} else {
+			outFile = "output" + ext
+		}
 	}
-
-	fmt.Printf(" ---- ---- ---- ----\n")
-	fmt.Printf("  output written on '%s'.\n", ordPath(pdst))
 }
 
-func writeText(pdst, text string) {
-	wdst, err := os.Create(pdst)
-	sceAssert(err)
-	defer wdst.Close()
-
-	_, err = io.WriteString(wdst, text)
-	sceAssert(err)
+// newCliJob makes a job from the command line options. The options
+// are checked here.
+func newCliJob() *scJob {
+	j := newOptionJob()
+	j.sources = append([]string(nil), inSources...)
+	for name, text := range evalTexts {
+		j.evalTexts[name] = text
+	}
+	j.inFile, j.outFile = inFile, outFile
+	j.depFile = depFileVal
+	return j
 }
 
-func writePdf(ppdf string) {
-	fmt.Printf(" ---- ---- ---- ----\n")
-	fmt.Printf("  writing pages ...\n")
-
-	wpdf, err := os.Create(ppdf)
-	sceAssert(err)
-	defer wpdf.Close()
-
-	doc := new(scpdf.Doc)
-	doc.SetDocInfo(map[string]string{
-		"title":   "\u2603",
-		"creator": "scSATySFi",
-	})
-	doc.AddPage(mufflerColor)
-	_, err = doc.WriteTo(wpdf)
+// newOptionJob makes a job that has the settings given by the command
+// line options, but has no input or output.
+func newOptionJob() *scJob {
+	j := newJob()
+	if mufflerVal != "" {
+		sceAssert(j.setMuffler(mufflerVal))
+	}
+	if textModeVal != "" {
+		sceAssert(j.setTextMode(textModeVal))
+	}
+	if dpiVal != 0 {
+		j.dpi = dpiVal
+	}
+	j.markdown = (markdownVal != "")
+	j.transparent = isTransparent
+	j.template = templateVal
+	j.validateXml = validateXml
+	j.highlight = highlightVal
+	j.typeCheckOnly, j.byteComp = typeCheckOnly, byteComp
+	j.config, j.noDefaultConfig = config, noDefaultConfig
+	j.dumpDeps = dumpDepsVal
+	defines, err := scParseDefines(defineVals)
 	sceAssert(err)
+	j.defines = defines
+	j.lexicon = lexiconVal
+	j.inputEncoding = inputEncodingVal
+	j.termDepth = sctTermColorDepth()
+	j.log, j.out = os.Stdout, os.Stdout
+	sceAssert(j.check())
+	return j
 }
 
-func readFile(psrc string, value scValue) {
-	fmt.Printf(" ---- ---- ---- ----\n")
-	fmt.Printf("  type checking '%s' ...\n", ordInPath(psrc))
-	fmt.Printf("  type check passed. (%s)\n", value.vtype)
-
-	if !typeCheckOnly && value.vtype != scEssential {
-		scePanic(sceNonDocError(psrc, value.vtype))
+func main() {
+	defer sceExit()
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "repl":
+			runRepl(os.Args[2:])
+			return
+		case "serve":
+			runServe(os.Args[2:])
+			return
+		case "build":
+			runBuild(os.Args[2:])
+			return
+		}
 	}
-}
-
-func openInFile(psrc string) (io.ReadCloser, error) {
-	if evalVal == "" {
-		return os.Open(psrc)
+	readArg()
+	j := newCliJob()
+	if rpcMode {
+		runRpc()
+		return
 	}
-	buf := bytes.NewBuffer([]byte(evalVal))
-	return ioutil.NopCloser(buf), nil
-}
-
-func parseFile(psrc string) (value scValue) {
-	fmt.Printf("  parsing '%s' ...\n", ordInPath(psrc))
-
-	rsrc, err := openInFile(psrc)
-	sceAssert(err)
-	defer rsrc.Close()
-	if isMarkdown {
-		value, err = scParseMarkdown(rsrc)
-	} else {
-		value, err = scParseReader(rsrc)
+	if j.dumpDeps != "" {
+		j.writeDepGraph()
+		return
 	}
-	sceAssert(err)
-	return
+	if watchMode {
+		runWatch()
+		return
+	}
+	j.compile()
 }
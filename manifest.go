@@ -0,0 +1,165 @@
+// Copyright (c) 2018-2021 Takayuki YATO (aka. "ZR")
+//   GitHub:   https://github.com/zr-tex8r
+//   Twitter:  @zr_tex8r
+// Distributed under the MIT License.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// The project manifest, which build reads when no directory is given.
+//
+//	flags = ["--muffler=blue"]         # for all the documents
+//
+//	[[document]]
+//	name = "intro"
+//	inputs = ["preamble.scty", "intro.scty"]
+//	mode = "html"
+//	muffler = "cmyk:red,1"
+//	defines = ["draft"]
+//	output = "out/intro.html"
+//	flags = ["--validate-xml"]
+//
+// The paths in inputs and output are relative to the manifest. Without
+// output, the output is named after name, or else the last input. The
+// options are applied in the order of the global flags, the keys of the
+// document, its flags and the command line.
+const manifestName = "scsatysfi.toml"
+
+// manifestTarget is a document in the manifest, given as the arguments
+// to be parsed with argSpecList.
+type manifestTarget struct {
+	name   string
+	args   []string
+	output string // the default output, without the extension
+}
+
+var manifestDocKeys = map[string]bool{
+	"name": true, "inputs": true, "output": true, "mode": true,
+	"muffler": true, "markdown": true, "defines": true, "flags": true,
+}
+
+// readManifest reads the documents in the manifest; outFileOf gives the
+// output of a document, and no two documents may have the same one.
+func readManifest(path string, outFileOf func(t manifestTarget) string) (targets []manifestTarget) {
+	rsrc, err := os.Open(path)
+	sceAssert(err)
+	defer rsrc.Close()
+	tbl, err := tmlParse(path, rsrc)
+	sceAssert(err)
+
+	for key := range tbl {
+		if key != "flags" && key != "document" {
+			scePanic(sceConfigFileError(path, fmt.Sprintf("unknown key '%s'", key)))
+		}
+	}
+	flags := manifestStrings(path, "", tbl, "flags")
+	docs, ok := tbl["document"].([]tmlTable)
+	if !ok || len(docs) == 0 {
+		scePanic(sceConfigFileError(path, "no [[document]] is given"))
+	}
+	dir := filepath.Dir(path)
+	outNames := make(map[string]string)
+	for i, doc := range docs {
+		t := manifestTarget{name: fmt.Sprintf("document #%d", i+1)}
+		name, hasName := manifestString(path, t.name, doc, "name")
+		if hasName {
+			t.name = name
+		}
+		var keys []string
+		for key := range doc {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if !manifestDocKeys[key] {
+				scePanic(sceConfigFileError(path, fmt.Sprintf("%s: unknown key '%s'", t.name, key)))
+			}
+		}
+		t.args = append(t.args, flags...)
+		if mode, ok := manifestString(path, t.name, doc, "mode"); ok {
+			t.args = append(t.args, "--text-mode="+mode)
+		}
+		if muffler, ok := manifestString(path, t.name, doc, "muffler"); ok {
+			t.args = append(t.args, "--muffler="+muffler)
+		}
+		if md, ok := doc["markdown"]; ok {
+			if b, ok := md.(bool); !ok {
+				scePanic(sceConfigFileError(path, t.name+": 'markdown' must be a boolean"))
+			} else if b {
+				t.args = append(t.args, "--markdown=md")
+			}
+		}
+		for _, def := range manifestStrings(path, t.name, doc, "defines") {
+			t.args = append(t.args, "--define="+def)
+		}
+		if out, ok := manifestString(path, t.name, doc, "output"); ok {
+			t.args = append(t.args, "-o", manifestPath(dir, out))
+		}
+		t.args = append(t.args, manifestStrings(path, t.name, doc, "flags")...)
+		inputs := manifestStrings(path, t.name, doc, "inputs")
+		if len(inputs) == 0 {
+			scePanic(sceConfigFileError(path, t.name+": no 'inputs' is given"))
+		}
+		for _, in := range inputs {
+			t.args = append(t.args, manifestPath(dir, in))
+		}
+		if hasName {
+			t.output = manifestPath(dir, name)
+		} else {
+			t.output = changeExt(manifestPath(dir, inputs[len(inputs)-1]), "")
+		}
+		out := fullInPath(outFileOf(t))
+		if prev, ok := outNames[out]; ok {
+			scePanic(sceConfigFileError(path, fmt.Sprintf("%s and %s have the same output '%s'",
+				prev, t.name, ordInPath(out))))
+		}
+		outNames[out] = t.name
+		targets = append(targets, t)
+	}
+	return
+}
+
+func manifestPath(dir, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
+
+func manifestString(path, what string, t tmlTable, key string) (string, bool) {
+	v, ok := t[key]
+	if !ok {
+		return "", false
+	}
+	s, ok := v.(string)
+	if !ok {
+		scePanic(sceConfigFileError(path, fmt.Sprintf("%s: '%s' must be a string", what, key)))
+	}
+	return s, true
+}
+
+func manifestStrings(path, what string, t tmlTable, key string) (ss []string) {
+	v, ok := t[key]
+	if !ok {
+		return nil
+	}
+	vs, ok := v.([]interface{})
+	for _, v := range vs {
+		s, sok := v.(string)
+		ok = ok && sok
+		ss = append(ss, s)
+	}
+	if !ok {
+		if what != "" {
+			what += ": "
+		}
+		scePanic(sceConfigFileError(path, fmt.Sprintf("%s'%s' must be an array of strings", what, key)))
+	}
+	return
+}
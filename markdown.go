@@ -14,6 +14,6 @@ func scParseMarkdown(rsrc io.Reader) (value scValue, err error) {
 		return
 	}
 	// there is no such thing as "invalid" Markdown
-	value = scValue{scEssential}
+	value = scValue{vtype: scEssential}
 	return
 }
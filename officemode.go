@@ -0,0 +1,328 @@
+// Copyright (c) 2018-2021 Takayuki YATO (aka. "ZR")
+//   GitHub:   https://github.com/zr-tex8r
+//   Twitter:  @zr_tex8r
+// Distributed under the MIT License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"image/color"
+	"image/png"
+	"time"
+)
+
+// Resolution of the picture embedded in office documents.
+const scoDpi = 150
+
+// Width of the picture on the page, in centimeters.
+const scoWidthCm = 12.0
+
+// scoPicture returns the PNG data of the figure and its size in centimeters.
+func scoPicture(muffler color.Color) (data []byte, wcm, hcm float64) {
+	img := scrRenderFigure(scoDpi, muffler, color.White)
+	buf := new(bytes.Buffer)
+	sceAssert(png.Encode(buf, img))
+	b := img.Bounds()
+	return buf.Bytes(), scoWidthCm, scoWidthCm * float64(b.Dy()) / float64(b.Dx())
+}
+
+// scoXml marshals a value into an XML document.
+func scoXml(v interface{}) []byte {
+	bs, err := xml.Marshal(v)
+	sceAssert(err)
+	return append([]byte(xml.Header), bs...)
+}
+
+//-------- ODT
+
+type odfManifest struct {
+	XMLName xml.Name           `xml:"manifest:manifest"`
+	NsMf    string             `xml:"xmlns:manifest,attr"`
+	Version string             `xml:"manifest:version,attr"`
+	Entries []odfManifestEntry `xml:"manifest:file-entry"`
+}
+
+type odfManifestEntry struct {
+	Path  string `xml:"manifest:full-path,attr"`
+	Mtype string `xml:"manifest:media-type,attr"`
+	Ver   string `xml:"manifest:version,attr,omitempty"`
+}
+
+type odfMeta struct {
+	XMLName  xml.Name `xml:"office:document-meta"`
+	NsOffice string   `xml:"xmlns:office,attr"`
+	NsMeta   string   `xml:"xmlns:meta,attr"`
+	NsDc     string   `xml:"xmlns:dc,attr"`
+	Version  string   `xml:"office:version,attr"`
+	Meta     struct {
+		Generator string `xml:"meta:generator"`
+		Title     string `xml:"dc:title"`
+		Language  string `xml:"dc:language"`
+		Created   string `xml:"meta:creation-date"`
+		Date      string `xml:"dc:date"`
+	} `xml:"office:meta"`
+}
+
+type odfContent struct {
+	XMLName  xml.Name `xml:"office:document-content"`
+	NsOffice string   `xml:"xmlns:office,attr"`
+	NsText   string   `xml:"xmlns:text,attr"`
+	NsDraw   string   `xml:"xmlns:draw,attr"`
+	NsSvg    string   `xml:"xmlns:svg,attr"`
+	NsXlink  string   `xml:"xmlns:xlink,attr"`
+	Version  string   `xml:"office:version,attr"`
+	Frame    odfFrame `xml:"office:body>office:text>text:p>draw:frame"`
+}
+
+type odfFrame struct {
+	Name   string `xml:"draw:name,attr"`
+	Anchor string `xml:"text:anchor-type,attr"`
+	Width  string `xml:"svg:width,attr"`
+	Height string `xml:"svg:height,attr"`
+	Image  struct {
+		Href    string `xml:"xlink:href,attr"`
+		Type    string `xml:"xlink:type,attr"`
+		Show    string `xml:"xlink:show,attr"`
+		Actuate string `xml:"xlink:actuate,attr"`
+	} `xml:"draw:image"`
+	Title string `xml:"svg:title"`
+}
+
+const odtMimetype = "application/vnd.oasis.opendocument.text"
+
+func (j *scJob) makeOdtBytes() []byte {
+	const nsOffice = "urn:oasis:names:tc:opendocument:xmlns:office:1.0"
+	pic, wcm, hcm := scoPicture(j.mufflerColor)
+	now := time.Now().UTC().Format("2006-01-02T15:04:05")
+
+	mf := odfManifest{
+		NsMf:    "urn:oasis:names:tc:opendocument:xmlns:manifest:1.0",
+		Version: "1.2",
+		Entries: []odfManifestEntry{
+			{"/", odtMimetype, "1.2"},
+			{"content.xml", "text/xml", ""},
+			{"meta.xml", "text/xml", ""},
+			{"Pictures/snowman.png", "image/png", ""},
+		},
+	}
+
+	meta := odfMeta{
+		NsOffice: nsOffice,
+		NsMeta:   "urn:oasis:names:tc:opendocument:xmlns:meta:1.0",
+		NsDc:     "http://purl.org/dc/elements/1.1/",
+		Version:  "1.2",
+	}
+	meta.Meta.Generator = progName + "/" + version
+	meta.Meta.Title = docTitle
+	meta.Meta.Language = docLang
+	meta.Meta.Created = now
+	meta.Meta.Date = now
+
+	content := odfContent{
+		NsOffice: nsOffice,
+		NsText:   "urn:oasis:names:tc:opendocument:xmlns:text:1.0",
+		NsDraw:   "urn:oasis:names:tc:opendocument:xmlns:drawing:1.0",
+		NsSvg:    "urn:oasis:names:tc:opendocument:xmlns:svg-compatible:1.0",
+		NsXlink:  "http://www.w3.org/1999/xlink",
+		Version:  "1.2",
+	}
+	fr := &content.Frame
+	fr.Name, fr.Anchor = "snowman", "as-char"
+	fr.Width, fr.Height = scgReal(wcm)+"cm", scgReal(hcm)+"cm"
+	fr.Image.Href = "Pictures/snowman.png"
+	fr.Image.Type, fr.Image.Show, fr.Image.Actuate = "simple", "embed", "onLoad"
+	fr.Title = docTitle
+
+	pkg := newSczPackage()
+	pkg.addMimetype(odtMimetype)
+	pkg.add("META-INF/manifest.xml", scoXml(mf))
+	pkg.add("meta.xml", scoXml(meta))
+	pkg.add("content.xml", scoXml(content))
+	pkg.add("Pictures/snowman.png", pic)
+	return pkg.bytes()
+}
+
+//-------- DOCX
+
+type opcTypes struct {
+	XMLName   xml.Name      `xml:"Types"`
+	Ns        string        `xml:"xmlns,attr"`
+	Defaults  []opcDefault  `xml:"Default"`
+	Overrides []opcOverride `xml:"Override"`
+}
+
+type opcDefault struct {
+	Ext   string `xml:"Extension,attr"`
+	Ctype string `xml:"ContentType,attr"`
+}
+
+type opcOverride struct {
+	Part  string `xml:"PartName,attr"`
+	Ctype string `xml:"ContentType,attr"`
+}
+
+type opcRels struct {
+	XMLName xml.Name `xml:"Relationships"`
+	Ns      string   `xml:"xmlns,attr"`
+	Rels    []opcRel `xml:"Relationship"`
+}
+
+type opcRel struct {
+	Id     string `xml:"Id,attr"`
+	Type   string `xml:"Type,attr"`
+	Target string `xml:"Target,attr"`
+}
+
+type opcCore struct {
+	XMLName   xml.Name `xml:"cp:coreProperties"`
+	NsCp      string   `xml:"xmlns:cp,attr"`
+	NsDc      string   `xml:"xmlns:dc,attr"`
+	NsDcterms string   `xml:"xmlns:dcterms,attr"`
+	NsXsi     string   `xml:"xmlns:xsi,attr"`
+	Title     string   `xml:"dc:title"`
+	Creator   string   `xml:"dc:creator"`
+	Language  string   `xml:"dc:language"`
+	Created   opcDate  `xml:"dcterms:created"`
+	Modified  opcDate  `xml:"dcterms:modified"`
+}
+
+type opcDate struct {
+	Type  string `xml:"xsi:type,attr"`
+	Value string `xml:",chardata"`
+}
+
+type opcApp struct {
+	XMLName     xml.Name `xml:"Properties"`
+	Ns          string   `xml:"xmlns,attr"`
+	Application string   `xml:"Application"`
+	AppVersion  string   `xml:"AppVersion"`
+}
+
+type docxDocument struct {
+	XMLName xml.Name   `xml:"w:document"`
+	NsW     string     `xml:"xmlns:w,attr"`
+	NsR     string     `xml:"xmlns:r,attr"`
+	NsWp    string     `xml:"xmlns:wp,attr"`
+	NsA     string     `xml:"xmlns:a,attr"`
+	NsPic   string     `xml:"xmlns:pic,attr"`
+	Inline  docxInline `xml:"w:body>w:p>w:r>w:drawing>wp:inline"`
+}
+
+type docxInline struct {
+	Extent docxExtent `xml:"wp:extent"`
+	DocPr  docxNvPr   `xml:"wp:docPr"`
+	Data   struct {
+		Uri string  `xml:"uri,attr"`
+		Pic docxPic `xml:"pic:pic"`
+	} `xml:"a:graphic>a:graphicData"`
+}
+
+type docxExtent struct {
+	Cx int64 `xml:"cx,attr"`
+	Cy int64 `xml:"cy,attr"`
+}
+
+type docxNvPr struct {
+	Id    int    `xml:"id,attr"`
+	Name  string `xml:"name,attr"`
+	Descr string `xml:"descr,attr,omitempty"`
+}
+
+type docxPic struct {
+	NvPr     docxNvPr `xml:"pic:nvPicPr>pic:cNvPr"`
+	CNvPicPr struct{} `xml:"pic:nvPicPr>pic:cNvPicPr"`
+	Blip     struct {
+		Embed string `xml:"r:embed,attr"`
+	} `xml:"pic:blipFill>a:blip"`
+	FillRect struct{} `xml:"pic:blipFill>a:stretch>a:fillRect"`
+	Xfrm     struct {
+		Off struct {
+			X int64 `xml:"x,attr"`
+			Y int64 `xml:"y,attr"`
+		} `xml:"a:off"`
+		Ext docxExtent `xml:"a:ext"`
+	} `xml:"pic:spPr>a:xfrm"`
+	Geom struct {
+		Prst  string   `xml:"prst,attr"`
+		AvLst struct{} `xml:"a:avLst"`
+	} `xml:"pic:spPr>a:prstGeom"`
+}
+
+func (j *scJob) makeDocxBytes() []byte {
+	const (
+		relBase = "http://schemas.openxmlformats.org/officeDocument/2006/relationships"
+		relNs   = "http://schemas.openxmlformats.org/package/2006/relationships"
+		emuCm   = 360000
+	)
+	pic, wcm, hcm := scoPicture(j.mufflerColor)
+	now := time.Now().UTC().Format("2006-01-02T15:04:05Z")
+
+	types := opcTypes{
+		Ns: "http://schemas.openxmlformats.org/package/2006/content-types",
+		Defaults: []opcDefault{
+			{"rels", "application/vnd.openxmlformats-package.relationships+xml"},
+			{"xml", "application/xml"},
+			{"png", "image/png"},
+		},
+		Overrides: []opcOverride{
+			{"/word/document.xml", "application/vnd.openxmlformats-officedocument.wordprocessingml.document.main+xml"},
+			{"/docProps/core.xml", "application/vnd.openxmlformats-package.core-properties+xml"},
+			{"/docProps/app.xml", "application/vnd.openxmlformats-officedocument.extended-properties+xml"},
+		},
+	}
+
+	rels := opcRels{Ns: relNs, Rels: []opcRel{
+		{"rId1", relBase + "/officeDocument", "word/document.xml"},
+		{"rId2", "http://schemas.openxmlformats.org/package/2006/relationships/metadata/core-properties", "docProps/core.xml"},
+		{"rId3", relBase + "/extended-properties", "docProps/app.xml"},
+	}}
+	docRels := opcRels{Ns: relNs, Rels: []opcRel{
+		{"rId1", relBase + "/image", "media/snowman.png"},
+	}}
+
+	core := opcCore{
+		NsCp:      "http://schemas.openxmlformats.org/package/2006/metadata/core-properties",
+		NsDc:      "http://purl.org/dc/elements/1.1/",
+		NsDcterms: "http://purl.org/dc/terms/",
+		NsXsi:     "http://www.w3.org/2001/XMLSchema-instance",
+		Title:     docTitle,
+		Creator:   progName,
+		Language:  docLang,
+		Created:   opcDate{"dcterms:W3CDTF", now},
+		Modified:  opcDate{"dcterms:W3CDTF", now},
+	}
+	app := opcApp{
+		Ns:          "http://schemas.openxmlformats.org/officeDocument/2006/extended-properties",
+		Application: progName,
+		AppVersion:  version,
+	}
+
+	ext := docxExtent{int64(wcm * emuCm), int64(hcm * emuCm)}
+	doc := docxDocument{
+		NsW:   "http://schemas.openxmlformats.org/wordprocessingml/2006/main",
+		NsR:   relBase,
+		NsWp:  "http://schemas.openxmlformats.org/drawingml/2006/wordprocessingDrawing",
+		NsA:   "http://schemas.openxmlformats.org/drawingml/2006/main",
+		NsPic: "http://schemas.openxmlformats.org/drawingml/2006/picture",
+	}
+	in := &doc.Inline
+	in.Extent = ext
+	in.DocPr = docxNvPr{1, "snowman", docTitle}
+	in.Data.Uri = doc.NsPic
+	in.Data.Pic.NvPr = docxNvPr{Id: 0, Name: "snowman.png"}
+	in.Data.Pic.Blip.Embed = "rId1"
+	in.Data.Pic.Xfrm.Ext = ext
+	in.Data.Pic.Geom.Prst = "rect"
+
+	pkg := newSczPackage()
+	pkg.add("[Content_Types].xml", scoXml(types))
+	pkg.add("_rels/.rels", scoXml(rels))
+	pkg.add("docProps/core.xml", scoXml(core))
+	pkg.add("docProps/app.xml", scoXml(app))
+	pkg.add("word/document.xml", scoXml(doc))
+	pkg.add("word/_rels/document.xml.rels", scoXml(docRels))
+	pkg.add("word/media/snowman.png", pic)
+	return pkg.bytes()
+}
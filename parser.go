@@ -7,7 +7,13 @@ package main
 
 import (
 	"bufio"
+	"fmt"
 	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
 )
 
 //--------scVType
@@ -28,37 +34,303 @@ func (t scVType) String() string {
 //--------scValue
 
 type scValue struct {
-	vtype scVType
+	vtype   scVType
+	snowmen []scPos
 	// NB: no other data is needed
 }
 
+// scInclude is an include pragma processed, at line of from.
+type scInclude struct {
+	from string
+	line int
+	to   string
+}
+
+// scPos is a source position; col counts in the same manner as
+// the diagnostics do.
+type scPos struct {
+	src       string
+	line, col int
+}
+
 //--------scParse
 
-func scParseReader(rsrc io.Reader) (value scValue, err error) {
-	lno, csrlen, ssrc := 0, 0, bufio.NewScanner(rsrc)
-	vt, curvt := scNix, scNix
+// scParser parses a sequence of input sources, which are treated as
+// if they were concatenated.
+type scParser struct {
+	csrlen  int
+	vtype   scVType
+	snowmen []scPos
+	src     string   // the last source
+	lno     int      // the last line number in src
+	chain   []string // the sources being parsed, outermost first
+	// the include pragmas processed, and the type of each source (with
+	// the files it includes)
+	includes []scInclude
+	srcTypes map[string]scVType
+	cond     *scCond // the conditional pragmas
+	// the lexical profile in effect, which a lexicon pragma changes
+	// until the end of the source, and the function to look it up
+	lex      *scLexTable
+	lexTable func(name string) *scLexTable
+	// include finds the file designated by an include pragma in src;
+	// if nil, the pragma is an ordinary comment.
+	include func(src, name string) (string, error)
+	open    func(path string) (io.ReadCloser, error) // opens the file
+	// onLine, if not nil, receives each line read with its tokens and
+	// type; the pragmas and the lines turned off are comments.
+	onLine func(src string, lno int, toks []scToken, vt scVType)
+}
+
+func newScParser() *scParser {
+	return &scParser{
+		vtype:    scNix,
+		srcTypes: make(map[string]scVType),
+		cond:     newScCond(nil),
+		lex:      scDefaultLex,
+		lexTable: func(name string) *scLexTable { return scLexBuiltins[name] },
+		open:     func(path string) (io.ReadCloser, error) { return os.Open(path) },
+	}
+}
+
+// scSource is a source being parsed.
+type scSource struct {
+	name  string
+	vtype scVType     // with the files it includes
+	lex   *scLexTable // the profile at the start, restored at the end
+	base  int         // the depth of the conditionals at the start
+}
+
+// parse reads an input source, named src in diagnostics.
+func (p *scParser) parse(src string, rsrc io.Reader) error {
+	s := p.begin(src)
+	ssrc := bufio.NewScanner(rsrc)
 	for ssrc.Scan() {
-		lno += 1
-		if vt, csrlen, err = scParseLine(lno, csrlen, ssrc.Text()); err != nil {
-			return
+		if err := p.parseLine(s, ssrc.Text()); err != nil {
+			return err
 		}
-		if vt == scEssential {
-			curvt = scEssential
+	}
+	if err := ssrc.Err(); err != nil {
+		return err
+	}
+	return p.end(s)
+}
+
+// begin starts a source, whose lines are given by parseLine.
+func (p *scParser) begin(src string) *scSource {
+	p.src, p.lno = src, 0
+	p.chain = append(p.chain, src)
+	return &scSource{src, scNix, p.lex, len(p.cond.stack)}
+}
+
+// parseLine parses the next line of the source s.
+func (p *scParser) parseLine(s *scSource, line string) error {
+	p.lno += 1
+	if kw, arg, acol, ok := scPragma(p.lex, line); ok && p.csrlen == 0 {
+		if isCond, err := p.cond.pragma(s.name, p.lno, kw, arg, acol, s.base); isCond {
+			if err != nil {
+				return err
+			}
+			p.reportLine(line, scNix)
+			return nil
+		}
+		if kw == "include" && p.include != nil && p.cond.enabled() {
+			p.reportLine(line, scNix)
+			path, err := p.parseInclude(line, arg, acol)
+			if err != nil {
+				return err
+			}
+			if p.srcTypes[path] == scEssential {
+				s.vtype = scEssential
+			}
+			return nil
+		}
+		if kw == "lexicon" && p.cond.enabled() {
+			p.reportLine(line, scNix)
+			if p.lex = p.lexTable(arg); p.lex == nil {
+				msg := fmt.Sprintf("unknown lexical profile '%s'", arg)
+				return scePragmaError(s.name, p.lno, acol, acol+len(arg), msg)
+			}
+			return nil
 		}
 	}
-	if err = ssrc.Err(); err != nil {
-		return
+	if !p.cond.enabled() { // taken as a comment
+		p.report(scDisabledLine(line), scNix)
+		return nil
+	}
+	vt, sms, csrlen, err := scParseLine(p.lex, s.name, p.lno, p.csrlen, line)
+	if err != nil {
+		return err
 	}
-	if csrlen > 0 {
-		err = sceBadCommentError(lno + 1)
+	if vt == scEssential {
+		p.vtype, s.vtype = scEssential, scEssential
+	}
+	p.snowmen = append(p.snowmen, sms...)
+	p.reportLine(line, vt)
+	p.csrlen = csrlen
+	return nil
+}
+
+// end finishes the source s, whose conditionals must be closed.
+func (p *scParser) end(s *scSource) error {
+	p.chain, p.lex = p.chain[:len(p.chain)-1], s.lex
+	if t, ok := p.srcTypes[s.name]; !ok || t < s.vtype {
+		p.srcTypes[s.name] = s.vtype
+	}
+	return p.cond.finish(s.base)
+}
+
+func (p *scParser) report(toks []scToken, vt scVType) {
+	if p.onLine != nil {
+		p.onLine(p.src, p.lno, toks, vt)
+	}
+}
+
+// reportLine reports a line parsed, which is lexed again only if
+// onLine is given.
+func (p *scParser) reportLine(line string, vt scVType) {
+	if p.onLine != nil {
+		toks, _, _ := scLexLine(p.lex, p.src, p.lno, p.csrlen, line)
+		p.report(toks, vt)
+	}
+}
+
+// parseInclude parses the file designated by the include pragma in
+// the current line, as a part of the current source. The included file
+// must not end in a block comment.
+func (p *scParser) parseInclude(line, arg string, acol int) (string, error) {
+	src, lno := p.src, p.lno
+	name, err := strconv.Unquote(arg)
+	if err != nil || !strings.HasPrefix(arg, `"`) {
+		return "", scePragmaError(src, lno, acol, acol+len(arg), "include needs a file name in double quotes")
+	}
+	path, err := p.include(src, name)
+	if err != nil {
+		return "", err
+	}
+	for i, s := range p.chain {
+		if scSameSource(s, path) {
+			chain := append(append([]string(nil), p.chain[i:]...), path)
+			return "", sceIncludeCycleError(src, lno, len(line), chain)
+		}
+	}
+	p.includes = append(p.includes, scInclude{src, lno, path})
+	rinc, err := p.open(path)
+	if err != nil {
+		return "", err
+	}
+	defer rinc.Close()
+	if err := p.parse(path, rinc); err != nil {
+		return "", err
+	}
+	if p.csrlen > 0 {
+		return "", sceBadCommentError(path, p.lno+1)
+	}
+	p.src, p.lno = src, lno
+	return path, nil
+}
+
+func scSameSource(a, b string) bool {
+	return fullInPath(a) == fullInPath(b)
+}
+
+// finish checks the end of input and returns the resulting value.
+func (p *scParser) finish() (value scValue, err error) {
+	if p.csrlen > 0 {
+		err = sceBadCommentError(p.src, p.lno+1)
 		return
 	}
-	value = scValue{curvt}
+	value = scValue{p.vtype, p.snowmen}
 	return
 }
 
-func scParseLine(lno, csrlen int, line string) (vt scVType, rcsrlen int, err error) {
+func scParseReader(lt *scLexTable, src string, rsrc io.Reader) (value scValue, err error) {
+	p := newScParser()
+	p.lex = lt
+	if err = p.parse(src, rsrc); err != nil {
+		return
+	}
+	return p.finish()
+}
+
+func scParseLine(lt *scLexTable, src string, lno, csrlen int, line string) (vt scVType, sms []scPos, rcsrlen int, err error) {
 	vt = scNix
+	toks, rcsrlen, err := scLexLine(lt, src, lno, csrlen, line)
+	for _, tok := range toks {
+		if tok.kind == scTokSnowman {
+			vt = scEssential
+			sms = append(sms, scPos{src, lno, tok.col})
+		}
+	}
+	return
+}
+
+// scPragma recognizes a pragma, which is a block comment alone in a
+// line, opened and closed by runs of two sushi and beginning with '#',
+// such as `@@#include "file.scty" @@`. Other comments are not pragmas,
+// whatever they say. It returns the word after '#' as the keyword, and
+// the rest with the column where it starts.
+func scPragma(lt *scLexTable, line string) (kw, arg string, acol int, ok bool) {
+	toks, csrlen, err := scLexLine(lt, "", 0, 0, line)
+	if err != nil || csrlen != 0 {
+		return
+	}
+	var ts []scToken
+	for _, tok := range toks {
+		if tok.kind != scTokSpace {
+			ts = append(ts, tok)
+		}
+	}
+	if len(ts) != 3 || ts[0].kind != scTokSushi ||
+		ts[1].kind != scTokComment || ts[2].kind != scTokSushi ||
+		scClusterCount(ts[0].text) != 2 || scClusterCount(ts[2].text) != 2 ||
+		!strings.HasPrefix(ts[1].text, "#") {
+		return
+	}
+	text := strings.TrimRight(ts[1].text[1:], " \t")
+	i := strings.IndexAny(text, " \t")
+	if i < 0 {
+		i = len(text)
+	}
+	if i == 0 { // no keyword right after '#'
+		return
+	}
+	arg = strings.TrimLeft(text[i:], " \t")
+	return text[:i], arg, ts[1].col + 1 + len(text) - len(arg), true
+}
+
+//--------scToken
+
+type scTokKind int
+
+const (
+	scTokSpace = scTokKind(iota)
+	scTokSnowman
+	scTokDuck
+	scTokIgnored // text after a duck
+	scTokSushi
+	scTokComment // text inside a block comment
+)
+
+var scTokKindName = []string{
+	"space", "snowman", "duck", "ignored", "sushi", "comment",
+}
+
+func (k scTokKind) String() string {
+	return scTokKindName[k]
+}
+
+type scToken struct {
+	kind scTokKind
+	col  int
+	text string
+}
+
+// scLexLine splits a line into tokens by the profile lt; adjacent
+// characters of the same kind form one token, except for snowmen. The
+// unit is a grapheme cluster (see scCluster), so that a snowman with a
+// variation selector is one snowman.
+func scLexLine(lt *scLexTable, src string, lno, csrlen int, line string) (toks []scToken, rcsrlen int, err error) {
 	srlen := 0
 	onSRTerm := func() {
 		if csrlen == 0 {
@@ -68,25 +340,43 @@ func scParseLine(lno, csrlen int, line string) (vt scVType, rcsrlen int, err err
 		} // else no-op
 		srlen = 0
 	}
-	for i, r := range line {
-		if r == '@' || r == '\U0001F363' { // SUSHI
+	push := func(kind scTokKind, i int, s string) {
+		if n := len(toks); n > 0 && kind != scTokSnowman && toks[n-1].kind == kind {
+			toks[n-1].text += s
+		} else {
+			toks = append(toks, scToken{kind, i, s})
+		}
+	}
+	for i, n := 0, 0; i < len(line); i += n {
+		n = scCluster(line[i:])
+		c := line[i : i+n]
+		kind, ok := lt.classify(c)
+		if ok && kind == scTokSushi {
 			srlen += 1
+			push(scTokSushi, i, c)
 			continue
 		} else if srlen > 0 { // sushi-run terminates
 			onSRTerm()
 		}
 		if csrlen > 0 { // in block comment
+			push(scTokComment, i, c)
 			continue
 		}
-		switch r {
-		case ' ', '\t':
-			// nop
-		case '8', '\u2603', '\u26C4', '\u26C7': // SNOWMAN
-			vt = scEssential
-		case '2', '\U0001F986': // DUCK
+		if !ok {
+			if r, size := utf8.DecodeRuneInString(c); r == utf8.RuneError && size == 1 {
+				err = sceBadByteError(src, lno, i, "UTF-8")
+			} else if size == n {
+				err = sceBadCharError(src, lno, i, i+1, r)
+			} else {
+				err = sceBadClusterError(src, lno, i, i+n, c)
+			}
 			return
-		default:
-			err = sceBadCharError(lno, i, i+1, r)
+		}
+		push(kind, i, c)
+		if kind == scTokDuck {
+			if j := i + n; j < len(line) {
+				push(scTokIgnored, j, line[j:])
+			}
 			return
 		}
 	}
@@ -96,3 +386,52 @@ func scParseLine(lno, csrlen int, line string) (vt scVType, rcsrlen int, err err
 	rcsrlen = csrlen
 	return
 }
+
+// scCluster returns the length of the first grapheme cluster in s, in
+// a simplified way enough for emoji: a character with the following
+// combining marks, variation selectors, skin tone modifiers and tags,
+// joined to the next one by ZWJ, or a pair of regional indicators.
+func scCluster(s string) int {
+	r, n := utf8.DecodeRuneInString(s)
+	if scIsRegional(r) {
+		if r, size := utf8.DecodeRuneInString(s[n:]); scIsRegional(r) {
+			n += size
+		}
+		return n
+	}
+	for n < len(s) {
+		r, size := utf8.DecodeRuneInString(s[n:])
+		switch {
+		case scIsExtender(r):
+			n += size
+		case r == '\u200D': // ZWJ
+			n += size
+			if n < len(s) {
+				_, size = utf8.DecodeRuneInString(s[n:])
+				n += size
+			}
+		default:
+			return n
+		}
+	}
+	return n
+}
+
+// scClusterCount returns the number of grapheme clusters in s.
+func scClusterCount(s string) (count int) {
+	for i := 0; i < len(s); i += scCluster(s[i:]) {
+		count++
+	}
+	return
+}
+
+func scIsRegional(r rune) bool {
+	return '\U0001F1E6' <= r && r <= '\U0001F1FF'
+}
+
+// scIsExtender tells whether r extends the preceding character.
+func scIsExtender(r rune) bool {
+	return unicode.In(r, unicode.Mn, unicode.Me, unicode.Variation_Selector) ||
+		'\U0001F3FB' <= r && r <= '\U0001F3FF' || // skin tone modifiers
+		'\U000E0020' <= r && r <= '\U000E007F' // tags
+}
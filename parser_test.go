@@ -0,0 +1,193 @@
+// Copyright (c) 2018-2021 Takayuki YATO (aka. "ZR")
+//   GitHub:   https://github.com/zr-tex8r
+//   Twitter:  @zr_tex8r
+// Distributed under the MIT License.
+
+package main
+
+import (
+	"io"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestScPragma(t *testing.T) {
+	tests := []struct {
+		line    string
+		kw, arg string
+		acol    int
+		ok      bool
+	}{
+		{`@@#include "a.scty" @@`, "include", `"a.scty"`, 11, true},
+		{`  @@#if  draft@@  `, "if", "draft", 9, true},
+		{`@@#endif @@`, "endif", "", 8, true},
+		{"\U0001F363\U0001F363#lexicon emoji \U0001F363\U0001F363", "lexicon", "emoji", 17, true},
+		{`@ if you read this @`, "", "", 0, false},
+		{`@ include the preamble here @`, "", "", 0, false},
+		{`@@ include "a.scty" @@`, "", "", 0, false},
+		{`@@# include "a.scty" @@`, "", "", 0, false},
+		{`@#include "a.scty"@`, "", "", 0, false},
+		{`@@@#include "a.scty" @@@`, "", "", 0, false},
+		{`@@#include "a.scty" @@ 8`, "", "", 0, false},
+	}
+	for _, tt := range tests {
+		kw, arg, acol, ok := scPragma(scDefaultLex, tt.line)
+		if kw != tt.kw || arg != tt.arg || acol != tt.acol || ok != tt.ok {
+			t.Errorf("scPragma(%q) = %q, %q, %d, %v; want %q, %q, %d, %v",
+				tt.line, kw, arg, acol, ok, tt.kw, tt.arg, tt.acol, tt.ok)
+		}
+	}
+}
+
+// Comments that merely read like pragmas are ordinary comments, even
+// when the parser follows includes.
+func TestScParserOrdinaryComments(t *testing.T) {
+	text := strings.Join([]string{
+		"@ if you read this @",
+		"8",
+		"@ include the preamble here @",
+		"@@ endif @@",
+		"@ lexicon emoji @ 8",
+	}, "\n")
+	p := newScParser()
+	p.include = func(src, name string) (string, error) {
+		t.Fatalf("include called for %q", name)
+		return "", nil
+	}
+	if err := p.parse("(test)", strings.NewReader(text)); err != nil {
+		t.Fatal(err)
+	}
+	value, err := p.finish()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value.vtype != scEssential || len(value.snowmen) != 2 {
+		t.Errorf("got %v with %d snowmen; want essential with 2", value.vtype, len(value.snowmen))
+	}
+}
+
+// scTestParser makes a parser whose includes are the texts in files.
+func scTestParser(files map[string]string, defines map[string]string) *scParser {
+	p := newScParser()
+	p.cond = newScCond(defines)
+	p.include = func(src, name string) (string, error) {
+		if _, ok := files[name]; !ok {
+			return "", sceNotFoundError(name, []string{name})
+		}
+		return name, nil
+	}
+	p.open = func(path string) (io.ReadCloser, error) {
+		return ioutil.NopCloser(strings.NewReader(files[path])), nil
+	}
+	return p
+}
+
+func TestScParserPaths(t *testing.T) {
+	files := map[string]string{
+		"pre.scty":   "2 preamble\n@@#include \"mid.scty\" @@",
+		"mid.scty":   "8",
+		"cycle.scty": "@@#include \"cycle.scty\" @@",
+		"open.scty":  "@@#if x @@",
+		"lex.scty":   "@@#lexicon ascii @@",
+	}
+	tests := []struct {
+		name    string
+		text    string
+		defines map[string]string
+		vtype   scVType
+		snowmen []scPos
+		err     bool
+	}{
+		{"include", "2\n@@#include \"pre.scty\" @@\n2", nil, scEssential,
+			[]scPos{{"mid.scty", 1, 0}}, false},
+		{"include cycle", "@@#include \"cycle.scty\" @@", nil, scNix, nil, true},
+		{"include missing", "@@#include \"none.scty\" @@", nil, scNix, nil, true},
+		{"include open if", "@@#include \"open.scty\" @@\n@@#endif @@", nil, scNix, nil, true},
+		{"if on", "@@#if x @@\n8\n@@#else @@\n 8\n@@#endif @@", map[string]string{"x": ""}, scEssential,
+			[]scPos{{"(test)", 2, 0}}, false},
+		{"if off", "@@#if x=1 @@\n8\n@@#else @@\n 8\n@@#endif @@", map[string]string{"x": "2"}, scEssential,
+			[]scPos{{"(test)", 4, 1}}, false},
+		{"if unclosed", "@@#if x @@\n8", nil, scNix, nil, true},
+		{"endif alone", "@@#endif @@", nil, scNix, nil, true},
+		{"lexicon", "@@#lexicon emoji @@\n☃", nil, scEssential,
+			[]scPos{{"(test)", 2, 0}}, false},
+		{"lexicon narrows", "@@#lexicon emoji @@\n8", nil, scNix, nil, true},
+		{"lexicon unknown", "@@#lexicon zzz @@", nil, scNix, nil, true},
+		{"lexicon ends with include", "@@#include \"lex.scty\" @@\n8", nil, scEssential,
+			[]scPos{{"(test)", 2, 0}}, false},
+		{"cluster", "☃️ ⛄\U0001F3FB", nil, scEssential,
+			[]scPos{{"(test)", 1, 0}, {"(test)", 1, 7}}, false},
+		{"cluster zwj", "☃‍☃", nil, scNix, nil, true},
+	}
+	for _, tt := range tests {
+		p := scTestParser(files, tt.defines)
+		err := p.parse("(test)", strings.NewReader(tt.text))
+		var value scValue
+		if err == nil {
+			value, err = p.finish()
+		}
+		if (err != nil) != tt.err {
+			t.Errorf("%s: got error %v", tt.name, err)
+			continue
+		}
+		if err == nil && (value.vtype != tt.vtype || !reflect.DeepEqual(value.snowmen, tt.snowmen)) {
+			t.Errorf("%s: got %v %v; want %v %v", tt.name, value.vtype, value.snowmen, tt.vtype, tt.snowmen)
+		}
+	}
+}
+
+// The lines given one by one keep the comment and conditional states,
+// which end checks.
+func TestScParserLines(t *testing.T) {
+	p := scTestParser(nil, nil)
+	s := p.begin("(test)")
+	for _, line := range []string{"@@ 8", "@@", "@@#if x @@", "8"} {
+		if err := p.parseLine(s, line); err != nil {
+			t.Fatal(err)
+		}
+		if line == "@@ 8" && p.csrlen != 2 {
+			t.Errorf("csrlen = %d after %q; want 2", p.csrlen, line)
+		}
+	}
+	if p.csrlen != 0 || p.vtype != scNix {
+		t.Errorf("got csrlen %d and %v; want 0 and nix", p.csrlen, p.vtype)
+	}
+	if err := p.end(s); err == nil {
+		t.Error("end accepted an if without endif")
+	}
+}
+
+func TestScParseReader(t *testing.T) {
+	value, err := scParseReader(scDefaultLex, "(test)", strings.NewReader("@@\n8\n@@ 8"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value.vtype != scEssential || len(value.snowmen) != 1 || value.snowmen[0].line != 3 {
+		t.Errorf("got %v %v", value.vtype, value.snowmen)
+	}
+}
+
+// The errors of the conditional pragmas are put at the argument, or at
+// the keyword if there is none.
+func TestScCondErrorSpan(t *testing.T) {
+	tests := []struct {
+		text string
+		pos  scPos
+	}{
+		{"@@#if @@", scPos{"(test)", 1, 3}},
+		{"@@#if a b @@", scPos{"(test)", 1, 6}},
+		{"@@#else @@", scPos{"(test)", 1, 3}},
+		{"@@#if a @@\n@@#endif  x @@", scPos{"(test)", 2, 10}},
+		{"@@#if a @@\n@@#else @@\n@@#else @@", scPos{"(test)", 3, 3}},
+		{"8\n@@#if  a @@", scPos{"(test)", 2, 7}},
+	}
+	for _, tt := range tests {
+		_, err := scParseReader(scDefaultLex, "(test)", strings.NewReader(tt.text))
+		e, ok := err.(*sceError)
+		if !ok || e.pos == nil || *e.pos != tt.pos {
+			t.Errorf("%q: got %v; want an error at %v", tt.text, err, tt.pos)
+		}
+	}
+}
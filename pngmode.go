@@ -0,0 +1,28 @@
+// Copyright (c) 2018-2021 Takayuki YATO (aka. "ZR")
+//   GitHub:   https://github.com/zr-tex8r
+//   Twitter:  @zr_tex8r
+// Distributed under the MIT License.
+
+package main
+
+import (
+	"bytes"
+	"image/color"
+	"image/png"
+)
+
+const (
+	dfltDpi = 72
+	maxDpi  = 600 // keeps an A4 page within scrMaxPixels
+)
+
+func (j *scJob) makePngBytes() []byte {
+	var bg color.Color = color.White
+	if j.transparent {
+		bg = nil
+	}
+	img := scrRender(float64(j.dpi), j.mufflerColor, bg)
+	buf := new(bytes.Buffer)
+	sceAssert(png.Encode(buf, img))
+	return buf.Bytes()
+}
@@ -0,0 +1,122 @@
+// Copyright (c) 2018-2021 Takayuki YATO (aka. "ZR")
+//   GitHub:   https://github.com/zr-tex8r
+//   Twitter:  @zr_tex8r
+// Distributed under the MIT License.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image/color"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// scpsColorCode returns the PostScript operator that sets the color,
+// in the native color model of the value.
+func scpsColorCode(c color.Color) string {
+	comps := func(mv float64, vs ...uint32) string {
+		t := make([]string, len(vs))
+		for i, v := range vs {
+			t[i] = scgReal(float64(v) / mv)
+		}
+		return strings.Join(t, " ")
+	}
+	switch c := c.(type) {
+	case color.Gray:
+		return comps(0xFF, uint32(c.Y)) + " setgray"
+	case color.Gray16:
+		return comps(0xFFFF, uint32(c.Y)) + " setgray"
+	case color.CMYK:
+		return comps(0xFF, uint32(c.C), uint32(c.M), uint32(c.Y), uint32(c.K)) +
+			" setcmykcolor"
+	default:
+		r, g, b, _ := c.RGBA()
+		return comps(0xFFFF, r, g, b) + " setrgbcolor"
+	}
+}
+
+// scpsPathCode returns the code that constructs and paints a shape.
+func scpsPathCode(shape scgShape) string {
+	buf := new(bytes.Buffer)
+	for _, seg := range shape.segs {
+		for _, p := range seg.pts {
+			fmt.Fprint(buf, scgReal(p.x), " ", scgReal(p.y), " ")
+		}
+		switch seg.op {
+		case 'm':
+			fmt.Fprintln(buf, "moveto")
+		case 'l':
+			fmt.Fprintln(buf, "lineto")
+		case 'c':
+			fmt.Fprintln(buf, "curveto")
+		}
+	}
+	if shape.closed {
+		fmt.Fprint(buf, "closepath ")
+	}
+	switch {
+	case shape.fill && shape.stroke:
+		fmt.Fprintln(buf, "gsave fill grestore stroke")
+	case shape.fill:
+		fmt.Fprintln(buf, "fill")
+	default:
+		fmt.Fprintln(buf, "stroke")
+	}
+	return buf.String()
+}
+
+// makePsText generates a PostScript (Level 2) document; if eps is true,
+// the result is an EPS file whose bounding box is tight.
+func (j *scJob) makePsText(eps bool) string {
+	width, height := scgPageWidth, scgPageHeight
+	minx, miny, maxx, maxy := 0.0, 0.0, width, height
+	if eps {
+		minx, miny, maxx, maxy = scgBounds(width, height)
+	}
+	side, ox, oy := scgLayout(width, height)
+
+	buf := new(bytes.Buffer)
+	if eps {
+		buf.WriteString("%!PS-Adobe-3.0 EPSF-3.0\n")
+	} else {
+		buf.WriteString("%!PS-Adobe-3.0\n")
+	}
+	fmt.Fprintf(buf, "%%%%BoundingBox: %d %d %d %d\n",
+		int(math.Floor(minx)), int(math.Floor(miny)),
+		int(math.Ceil(maxx)), int(math.Ceil(maxy)))
+	fmt.Fprintf(buf, "%%%%HiResBoundingBox: %s %s %s %s\n",
+		scgReal(minx), scgReal(miny), scgReal(maxx), scgReal(maxy))
+	buf.WriteString("%%Creator: " + progName + " " + version + "\n")
+	buf.WriteString("%%Title: (snowman)\n")
+	buf.WriteString("%%LanguageLevel: 2\n")
+	buf.WriteString("%%Pages: 1\n")
+	buf.WriteString("%%EndComments\n")
+	if !eps {
+		buf.WriteString("%%BeginSetup\n")
+		fmt.Fprintf(buf, "<< /PageSize [%s %s] >> setpagedevice\n",
+			scgReal(width), scgReal(height))
+		buf.WriteString("%%EndSetup\n")
+	}
+	buf.WriteString("%%Page: 1 1\n")
+	fmt.Fprintln(buf, "gsave")
+	fmt.Fprintf(buf, "[%s 0 0 %s %s %s] concat\n",
+		scgReal(side), scgReal(side), scgReal(ox), scgReal(oy))
+	fmt.Fprintln(buf, "0 setgray 1 setlinejoin 1 setlinecap",
+		strconv.FormatFloat(scgLineWidth, 'f', -1, 64), "setlinewidth")
+	muffler := false
+	for _, shape := range scgShapes {
+		if shape.muffler && !muffler {
+			fmt.Fprintln(buf, scpsColorCode(j.mufflerColor))
+			muffler = true
+		}
+		fmt.Fprint(buf, scpsPathCode(shape))
+	}
+	fmt.Fprintln(buf, "grestore")
+	fmt.Fprintln(buf, "showpage")
+	buf.WriteString("%%Trailer\n")
+	buf.WriteString("%%EOF\n")
+	return buf.String()
+}
@@ -0,0 +1,263 @@
+// Copyright (c) 2018-2021 Takayuki YATO (aka. "ZR")
+//   GitHub:   https://github.com/zr-tex8r
+//   Twitter:  @zr_tex8r
+// Distributed under the MIT License.
+
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"sort"
+)
+
+// Number of samples per pixel side used for anti-aliasing.
+const scrSub = 4
+
+//--------scrCanvas
+
+// Maximum number of pixels of a canvas, which is 256 MiB in memory.
+const scrMaxPixels = 1 << 26
+
+// scrCanvas is an RGBA raster in device space, where the origin is at
+// the top left.
+type scrCanvas struct {
+	width, height int
+	img           *image.NRGBA
+}
+
+func newScrCanvas(width, height int) *scrCanvas {
+	if width <= 0 || height <= 0 || width > scrMaxPixels/height {
+		scePanic(fmt.Errorf("the page of %dx%d pixels is too large.", width, height))
+	}
+	return &scrCanvas{width, height, image.NewNRGBA(image.Rect(0, 0, width, height))}
+}
+
+// fillBackground paints the whole canvas opaquely.
+func (cv *scrCanvas) fillBackground(col color.Color) {
+	c := color.NRGBAModel.Convert(col).(color.NRGBA)
+	pix := cv.img.Pix
+	for i := 0; i < len(pix); i += 4 {
+		pix[i], pix[i+1], pix[i+2], pix[i+3] = c.R, c.G, c.B, c.A
+	}
+}
+
+// paint composites a coverage mask in the given color.
+func (cv *scrCanvas) paint(m *scrMask, col color.Color) {
+	r, g, b, a := scrColorComps(col)
+	pix := cv.img.Pix
+	for y := 0; y < m.h; y++ {
+		for x := 0; x < m.w; x++ {
+			cov := m.coverage(x, y)
+			if cov == 0 {
+				continue
+			}
+			i := cv.img.PixOffset(m.x0+x, m.y0+y)
+			da := float64(pix[i+3]) / 0xFF
+			sa := a * cov
+			oa := sa + da*(1-sa)
+			if oa == 0 {
+				continue
+			}
+			dk := da * (1 - sa) / 0xFF
+			pix[i] = scrByte((r*cov + float64(pix[i])*dk) / oa)
+			pix[i+1] = scrByte((g*cov + float64(pix[i+1])*dk) / oa)
+			pix[i+2] = scrByte((b*cov + float64(pix[i+2])*dk) / oa)
+			pix[i+3] = scrByte(oa)
+		}
+	}
+}
+
+// scrColorComps returns the premultiplied components in [0,1].
+func scrColorComps(col color.Color) (r, g, b, a float64) {
+	cr, cg, cb, ca := col.RGBA()
+	return float64(cr) / 0xFFFF, float64(cg) / 0xFFFF,
+		float64(cb) / 0xFFFF, float64(ca) / 0xFFFF
+}
+
+func scrByte(v float64) uint8 {
+	if v <= 0 {
+		return 0
+	} else if v >= 1 {
+		return 0xFF
+	}
+	return uint8(v*0xFF + 0.5)
+}
+
+//--------scrMask
+
+// scrMask holds the sample hits for a rectangular region of pixels.
+type scrMask struct {
+	x0, y0, w, h int
+	smp          []bool
+}
+
+// newScrMask makes a mask covering the given bounds (in device space),
+// clipped to the canvas.
+func newScrMask(cv *scrCanvas, minx, miny, maxx, maxy float64) *scrMask {
+	x0 := scrClamp(int(math.Floor(minx)), cv.width)
+	y0 := scrClamp(int(math.Floor(miny)), cv.height)
+	x1 := scrClamp(int(math.Ceil(maxx)), cv.width)
+	y1 := scrClamp(int(math.Ceil(maxy)), cv.height)
+	w, h := x1-x0, y1-y0
+	return &scrMask{x0, y0, w, h, make([]bool, w*h*scrSub*scrSub)}
+}
+
+func scrClamp(v, lmt int) int {
+	if v < 0 {
+		return 0
+	} else if v > lmt {
+		return lmt
+	}
+	return v
+}
+
+func (m *scrMask) coverage(x, y int) float64 {
+	n, sw := 0, m.w*scrSub
+	for j := 0; j < scrSub; j++ {
+		row := (y*scrSub + j) * sw
+		for i := 0; i < scrSub; i++ {
+			if m.smp[row+x*scrSub+i] {
+				n++
+			}
+		}
+	}
+	return float64(n) / (scrSub * scrSub)
+}
+
+// samplePos returns the device position of the center of a sample.
+func (m *scrMask) samplePos(i, j int) (float64, float64) {
+	return float64(m.x0) + (float64(i)+0.5)/scrSub,
+		float64(m.y0) + (float64(j)+0.5)/scrSub
+}
+
+// fill marks the samples inside the polygons, by the nonzero rule.
+func (m *scrMask) fill(polys [][]scgPoint) {
+	type crossing struct {
+		x   float64
+		dir int
+	}
+	sw, sh := m.w*scrSub, m.h*scrSub
+	var xs []crossing
+	for j := 0; j < sh; j++ {
+		_, yc := m.samplePos(0, j)
+		xs = xs[:0]
+		for _, poly := range polys {
+			for k := range poly {
+				a, b := poly[k], poly[(k+1)%len(poly)]
+				if (a.y <= yc && yc < b.y) || (b.y <= yc && yc < a.y) {
+					x := a.x + (yc-a.y)*(b.x-a.x)/(b.y-a.y)
+					dir := 1
+					if b.y < a.y {
+						dir = -1
+					}
+					xs = append(xs, crossing{x, dir})
+				}
+			}
+		}
+		sort.Slice(xs, func(p, q int) bool { return xs[p].x < xs[q].x })
+		wind := 0
+		for k := 0; k+1 < len(xs); k++ {
+			if wind += xs[k].dir; wind == 0 {
+				continue
+			}
+			for i := 0; i < sw; i++ {
+				xc, _ := m.samplePos(i, j)
+				if xs[k].x <= xc && xc < xs[k+1].x {
+					m.smp[j*sw+i] = true
+				}
+			}
+		}
+	}
+}
+
+// stroke marks the samples within the distance hw from the polylines,
+// which gives round caps and round joins.
+func (m *scrMask) stroke(polys [][]scgPoint, closed bool, hw float64) {
+	sw := m.w * scrSub
+	for _, poly := range polys {
+		if closed {
+			poly = append(poly[:len(poly):len(poly)], poly[0])
+		}
+		for k := 0; k+1 < len(poly); k++ {
+			a, b := poly[k], poly[k+1]
+			i0 := int(math.Floor((math.Min(a.x, b.x)-hw-float64(m.x0))*scrSub)) - 1
+			i1 := int(math.Ceil((math.Max(a.x, b.x)+hw-float64(m.x0))*scrSub)) + 1
+			j0 := int(math.Floor((math.Min(a.y, b.y)-hw-float64(m.y0))*scrSub)) - 1
+			j1 := int(math.Ceil((math.Max(a.y, b.y)+hw-float64(m.y0))*scrSub)) + 1
+			i0, i1 = scrClamp(i0, sw), scrClamp(i1, sw)
+			j0, j1 = scrClamp(j0, m.h*scrSub), scrClamp(j1, m.h*scrSub)
+			for j := j0; j < j1; j++ {
+				for i := i0; i < i1; i++ {
+					x, y := m.samplePos(i, j)
+					if scrSegDist(x, y, a, b) <= hw {
+						m.smp[j*sw+i] = true
+					}
+				}
+			}
+		}
+	}
+}
+
+func scrSegDist(x, y float64, a, b scgPoint) float64 {
+	dx, dy := b.x-a.x, b.y-a.y
+	t := 0.0
+	if l2 := dx*dx + dy*dy; l2 > 0 {
+		t = math.Max(0, math.Min(1, ((x-a.x)*dx+(y-a.y)*dy)/l2))
+	}
+	return math.Hypot(x-a.x-t*dx, y-a.y-t*dy)
+}
+
+//--------scrRender
+
+// scrRender rasterizes the essential figure on a page, with the given
+// resolution. A nil background leaves the page transparent.
+func scrRender(dpi float64, muffler, background color.Color) *image.NRGBA {
+	k := dpi / 72
+	cv := newScrCanvas(int(math.Ceil(scgPageWidth*k)), int(math.Ceil(scgPageHeight*k)))
+	if background != nil {
+		cv.fillBackground(background)
+	}
+	side, ox, oy := scgLayout(scgPageWidth, scgPageHeight)
+	hw := scgLineWidth * side * k / 2
+	for _, shape := range scgShapes {
+		polys := scgFlatten(shape.segs, scgCurveDiv)
+		minx, miny := math.Inf(1), math.Inf(1)
+		maxx, maxy := math.Inf(-1), math.Inf(-1)
+		for _, poly := range polys {
+			for i, p := range poly {
+				p = scgPoint{(ox + side*p.x) * k, float64(cv.height) - (oy+side*p.y)*k}
+				poly[i] = p
+				minx, miny = math.Min(minx, p.x), math.Min(miny, p.y)
+				maxx, maxy = math.Max(maxx, p.x), math.Max(maxy, p.y)
+			}
+		}
+		col := color.Color(color.Black)
+		if shape.muffler {
+			col = muffler
+		}
+		m := newScrMask(cv, minx-hw, miny-hw, maxx+hw, maxy+hw)
+		if shape.fill {
+			m.fill(polys)
+		}
+		if shape.stroke {
+			m.stroke(polys, shape.closed, hw)
+		}
+		cv.paint(m, col)
+	}
+	return cv.img
+}
+
+// scrRenderFigure is like scrRender, but crops the page to the bounding
+// box of the figure.
+func scrRenderFigure(dpi float64, muffler, background color.Color) *image.NRGBA {
+	img := scrRender(dpi, muffler, background)
+	k := dpi / 72
+	minx, miny, maxx, maxy := scgBounds(scgPageWidth, scgPageHeight)
+	h := float64(img.Bounds().Dy())
+	r := image.Rect(int(math.Floor(minx*k)), int(math.Floor(h-maxy*k)),
+		int(math.Ceil(maxx*k)), int(math.Ceil(h-miny*k)))
+	return img.SubImage(r).(*image.NRGBA)
+}
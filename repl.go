@@ -0,0 +1,179 @@
+// Copyright (c) 2018-2021 Takayuki YATO (aka. "ZR")
+//   GitHub:   https://github.com/zr-tex8r
+//   Twitter:  @zr_tex8r
+// Distributed under the MIT License.
+
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+const replHelp = //
+`  Lines not starting with ':' are source lines. Commands are:
+    :muffler COLOR       set the muffler color
+    :render MODE [FILE]  render the current document (MODE 'pdf' for PDF)
+    :show                show the current document
+    :reset               discard the current document
+    :help                show this message
+    :quit                leave the REPL
+`
+
+// replState holds the current document of the REPL session, which is
+// read by a parser of the job as the lines come.
+type replState struct {
+	lines  []string
+	parser *scParser
+	source *scSource
+	job    *scJob
+}
+
+func runRepl(args []string) {
+	argParseArgs(args, argSpecList, func(arg string) error {
+		inSources = append(inSources, arg)
+		return nil
+	})
+	if len(inSources) > 0 {
+		scePanic(errors.New("repl takes no input file or --eval."))
+	}
+	j := newCliJob()
+	j.inFile = "(repl)"
+	j.sources = []string{j.inFile}
+	j.evalTexts[j.inFile] = ""
+
+	fmt.Printf("  %s version %s REPL; type ':help' for help.\n", progName, version)
+	st := &replState{job: j}
+	st.restart()
+	ssrc := bufio.NewScanner(os.Stdin)
+	for {
+		if st.parser.csrlen > 0 {
+			fmt.Print("...> ")
+		} else {
+			fmt.Print("sc> ")
+		}
+		if !ssrc.Scan() {
+			fmt.Println()
+			break
+		}
+		line := ssrc.Text()
+		if strings.HasPrefix(line, ":") {
+			quit := false
+			if err := sceCatch(func() { quit = st.command(line) }); err != nil {
+				fmt.Print(sceDesc(err))
+			}
+			if quit {
+				break
+			}
+			continue
+		}
+		if err := sceCatch(func() { st.feed(line) }); err != nil {
+			fmt.Print(sceDesc(err))
+		}
+	}
+	sceAssert(ssrc.Err())
+}
+
+// restart makes a new parser and gives it the lines again, which are
+// known to be good; the progress is not shown again.
+func (st *replState) restart() {
+	j := st.job
+	log := j.log
+	j.log = ioutil.Discard
+	defer func() { j.log = log }()
+	st.parser = j.newParser()
+	st.source = st.parser.begin(j.inFile)
+	for _, line := range st.lines {
+		sceAssert(st.parser.parseLine(st.source, line))
+	}
+}
+
+// feed adds a source line to the document, unless it has errors.
+func (st *replState) feed(line string) {
+	lno := len(st.lines) + 1
+	p, vt := st.parser, scNix
+	p.onLine = func(src string, l int, toks []scToken, lvt scVType) {
+		if src == st.job.inFile && l == lno {
+			vt = lvt
+		}
+	}
+	if err := p.parseLine(st.source, line); err != nil {
+		st.restart() // the parser may be left halfway
+		scePanic(err)
+	}
+	p.onLine = nil
+	st.lines = append(st.lines, line)
+	comment := ""
+	if p.csrlen > 0 {
+		comment = " (in block comment)"
+	}
+	fmt.Printf("  line %d: %s; document: %s%s\n", lno, vt, p.vtype, comment)
+}
+
+// command executes a REPL command and tells whether to quit.
+func (st *replState) command(line string) bool {
+	fs := strings.Fields(line[1:])
+	if len(fs) == 0 {
+		return false
+	}
+	switch fs[0] {
+	case "q", "quit":
+		return true
+	case "h", "help":
+		fmt.Print(replHelp)
+	case "show":
+		for i, l := range st.lines {
+			fmt.Printf("%4d  %s\n", i+1, l)
+		}
+		fmt.Printf("  document: %s\n", st.parser.vtype)
+	case "reset":
+		st.lines = nil
+		st.restart()
+		fmt.Printf("  document discarded.\n")
+	case "muffler":
+		if len(fs) != 2 {
+			scePanic(errors.New("usage: :muffler COLOR"))
+		}
+		sceAssert(st.job.setMuffler(fs[1]))
+		fmt.Printf("  muffler color: %s\n", st.job.mufflerVal)
+	case "render":
+		if len(fs) < 2 || len(fs) > 3 {
+			scePanic(errors.New("usage: :render MODE [FILE]"))
+		}
+		st.render(fs[1:]...)
+	default:
+		scePanic(fmt.Errorf("unknown command ':%s'", fs[0]))
+	}
+	return false
+}
+
+func (st *replState) render(args ...string) {
+	j := st.job
+	mode := args[0]
+	if mode == "pdf" {
+		mode = ""
+	}
+	ext, ok := textModeExt[mode]
+	if !ok && mode != "term" {
+		scePanic(fmt.Errorf("unknown text mode value '%s'", args[0]))
+	}
+	if st.parser.csrlen > 0 {
+		scePanic(sceBadCommentError(j.inFile, len(st.lines)+1))
+	}
+	if st.parser.vtype != scEssential && mode != "highlight" {
+		scePanic(sceNonDocError(j.inFile, st.parser.vtype))
+	}
+	pdst := "output" + ext
+	if len(args) > 1 {
+		pdst = args[1]
+	}
+	j.evalTexts[j.inFile] = strings.Join(st.lines, "\n")
+	j.textMode = mode
+	sceAssert(j.check())
+	j.value = j.parseFiles(j.sources)
+	j.writeOutput(pdst)
+}
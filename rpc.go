@@ -0,0 +1,303 @@
+// Copyright (c) 2018-2021 Takayuki YATO (aka. "ZR")
+//   GitHub:   https://github.com/zr-tex8r
+//   Twitter:  @zr_tex8r
+// Distributed under the MIT License.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sort"
+)
+
+// The protocol is JSON-RPC 2.0, with one message (a request or a batch)
+// per line in each direction.
+
+const rpcSource = "(rpc)"
+
+// Error codes; the first ones are defined by JSON-RPC.
+const (
+	rpcParseError     = -32700
+	rpcInvalidRequest = -32600
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+	rpcInternalError  = -32603 // the data is a sceDiagnostic
+	rpcDocumentError  = -32000 // the data is a sceDiagnostic
+)
+
+type rpcRequest struct {
+	Jsonrpc string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params"`
+	Id      json.RawMessage `json:"id"` // absent for a notification
+}
+
+type rpcResponse struct {
+	Jsonrpc string          `json:"jsonrpc"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+	Id      json.RawMessage `json:"id"`
+}
+
+type rpcError struct {
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+// rpcParams is the parameters of all the methods. The source is given
+// either by text or by path.
+type rpcParams struct {
+	Text        *string `json:"text"`
+	Path        string  `json:"path"`
+	Markdown    bool    `json:"markdown"`
+	Mode        string  `json:"mode"`
+	Muffler     string  `json:"muffler"`
+	Dpi         int64   `json:"dpi"`
+	Transparent bool    `json:"transparent"`
+	Highlight   string  `json:"highlight"`
+	Template    string  `json:"template"`
+	Output      string  `json:"output"` // where render writes the output
+}
+
+type rpcParseResult struct {
+	Type    string        `json:"type"`
+	Snowmen []scjPosition `json:"snowmen"`
+}
+
+type rpcRenderResult struct {
+	Mode      string `json:"mode"`
+	MediaType string `json:"mediaType"`
+	Data      string `json:"data,omitempty"` // in base64
+	Path      string `json:"path,omitempty"`
+}
+
+type rpcModeInfo struct {
+	Name      string `json:"name"`
+	Extension string `json:"extension"`
+	MediaType string `json:"mediaType"`
+}
+
+var rpcMethods map[string]func(*rpcParams) interface{}
+
+func init() {
+	rpcMethods = map[string]func(*rpcParams) interface{}{
+		"parse":     rpcParse,
+		"typecheck": rpcTypecheck,
+		"render":    rpcRender,
+		"listModes": rpcListModes,
+	}
+}
+
+func runRpc() {
+	ssrc := bufio.NewScanner(os.Stdin)
+	ssrc.Buffer(nil, 64<<20)
+	enc := json.NewEncoder(os.Stdout)
+	for ssrc.Scan() {
+		msg := bytes.TrimSpace(ssrc.Bytes())
+		if len(msg) == 0 {
+			continue
+		}
+		if resp := rpcHandle(msg); resp != nil {
+			sceAssert(enc.Encode(resp))
+		}
+	}
+	sceAssert(ssrc.Err())
+}
+
+// rpcHandle processes a message and returns the response, which is
+// nil if nothing is to be sent back.
+func rpcHandle(msg []byte) interface{} {
+	if msg[0] != '[' {
+		if resp := rpcHandleOne(msg); resp != nil {
+			return resp
+		}
+		return nil
+	}
+	var batch []json.RawMessage
+	if err := json.Unmarshal(msg, &batch); err != nil {
+		return rpcErrorResponse(nil, rpcParseError, err.Error())
+	}
+	if len(batch) == 0 {
+		return rpcErrorResponse(nil, rpcInvalidRequest, "empty batch")
+	}
+	var resps []*rpcResponse
+	for _, m := range batch {
+		if resp := rpcHandleOne(m); resp != nil {
+			resps = append(resps, resp)
+		}
+	}
+	if len(resps) == 0 {
+		return nil
+	}
+	return resps
+}
+
+func rpcHandleOne(msg []byte) *rpcResponse {
+	var req rpcRequest
+	if err := json.Unmarshal(msg, &req); err != nil {
+		if _, ok := err.(*json.SyntaxError); ok {
+			return rpcErrorResponse(nil, rpcParseError, err.Error())
+		}
+		return rpcErrorResponse(nil, rpcInvalidRequest, err.Error())
+	}
+	if req.Jsonrpc != "2.0" || req.Method == "" {
+		return rpcErrorResponse(req.Id, rpcInvalidRequest, "not a JSON-RPC 2.0 request")
+	}
+	resp := rpcCall(&req)
+	if req.Id == nil {
+		return nil
+	}
+	return resp
+}
+
+func rpcErrorResponse(id json.RawMessage, code int, msg string) *rpcResponse {
+	return &rpcResponse{Jsonrpc: "2.0", Error: &rpcError{code, msg, nil}, Id: id}
+}
+
+// rpcCall invokes the method. The errors in the document are reported
+// with the diagnostic, and the ones in the request without.
+func rpcCall(req *rpcRequest) *rpcResponse {
+	proc, ok := rpcMethods[req.Method]
+	if !ok {
+		return rpcErrorResponse(req.Id, rpcMethodNotFound, "method not found: "+req.Method)
+	}
+	ps := new(rpcParams)
+	if len(req.Params) > 0 && string(req.Params) != "null" {
+		dec := json.NewDecoder(bytes.NewReader(req.Params))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(ps); err != nil {
+			return rpcErrorResponse(req.Id, rpcInvalidParams, err.Error())
+		}
+	}
+	var result interface{}
+	if err := sceCatch(func() { result = proc(ps) }); err != nil {
+		if perr, ok := err.(rpcParamsError); ok {
+			return rpcErrorResponse(req.Id, rpcInvalidParams, string(perr))
+		}
+		code := rpcDocumentError
+		if sceIsInternal(err) {
+			code = rpcInternalError
+		}
+		diag := sceDiagnosticFor(err)
+		resp := rpcErrorResponse(req.Id, code, diag.Message)
+		resp.Error.Data = diag
+		return resp
+	}
+	return &rpcResponse{Jsonrpc: "2.0", Result: result, Id: req.Id}
+}
+
+// rpcParamsError is given to scePanic for invalid parameters.
+type rpcParamsError string
+
+func (e rpcParamsError) Error() string {
+	return string(e)
+}
+
+// rpcJobFor makes the job for a call; the command line options give
+// the default settings. A source given by text cannot include files.
+func rpcJobFor(ps *rpcParams) *scJob {
+	j := newCliJob()
+	j.log, j.out = ioutil.Discard, ioutil.Discard
+	switch {
+	case ps.Text != nil && ps.Path == "":
+		j.evalTexts[rpcSource] = *ps.Text
+		j.sources = []string{rpcSource}
+		j.noInclude = true
+	case ps.Text == nil && ps.Path != "":
+		j.sources = []string{ps.Path}
+	default:
+		scePanic(rpcParamsError("exactly one of text and path is required"))
+	}
+	j.inFile = j.sources[0]
+	j.markdown = j.markdown || ps.Markdown
+
+	if ps.Mode != "" {
+		mode := ps.Mode
+		if mode == "pdf" {
+			mode = ""
+		}
+		if _, ok := textModeMediaType[mode]; !ok {
+			scePanic(rpcParamsError("unknown text mode value '" + ps.Mode + "'"))
+		}
+		j.textMode = mode
+	}
+	if ps.Muffler != "" {
+		if err := j.setMuffler(ps.Muffler); err != nil {
+			scePanic(rpcParamsError(err.Error()))
+		}
+	}
+	if ps.Dpi != 0 {
+		j.dpi = ps.Dpi
+	}
+	j.transparent = j.transparent || ps.Transparent
+	if ps.Highlight != "" {
+		j.highlight = ps.Highlight
+	}
+	if ps.Template != "" {
+		j.template = ps.Template
+	}
+	if err := j.check(); err != nil {
+		scePanic(rpcParamsError(err.Error()))
+	}
+	return j
+}
+
+func rpcParse(ps *rpcParams) interface{} {
+	j := rpcJobFor(ps)
+	return rpcParseResultOf(j.parseFiles(j.sources))
+}
+
+func rpcParseResultOf(value scValue) rpcParseResult {
+	result := rpcParseResult{value.vtype.String(), []scjPosition{}}
+	for _, p := range value.snowmen {
+		result.Snowmen = append(result.Snowmen, scjPosition{ordInPath(p.src), p.line, p.col})
+	}
+	return result
+}
+
+func rpcTypecheck(ps *rpcParams) interface{} {
+	j := rpcJobFor(ps)
+	j.typeCheckOnly = true // a nix document is a result, not an error
+	j.typeCheck()
+	return rpcParseResultOf(j.value)
+}
+
+func rpcRender(ps *rpcParams) interface{} {
+	j := rpcJobFor(ps)
+	j.typeCheck()
+	name := j.textMode
+	if name == "" {
+		name = "pdf"
+	}
+	result := rpcRenderResult{Mode: name, MediaType: j.mediaType()}
+	if ps.Output != "" && !j.isStdoutMode() {
+		j.writeOutput(ps.Output)
+		result.Path = ps.Output
+	} else {
+		result.Data = base64.StdEncoding.EncodeToString(j.render())
+	}
+	return result
+}
+
+func rpcListModes(ps *rpcParams) interface{} {
+	var names []string
+	for name := range textModeMediaType {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	modes := []rpcModeInfo{}
+	for _, name := range names {
+		info := rpcModeInfo{name, textModeExt[name], textModeMediaType[name]}
+		if name == "" {
+			info.Name = "pdf"
+		}
+		modes = append(modes, info)
+	}
+	return modes
+}
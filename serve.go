@@ -0,0 +1,199 @@
+// Copyright (c) 2018-2021 Takayuki YATO (aka. "ZR")
+//   GitHub:   https://github.com/zr-tex8r
+//   Twitter:  @zr_tex8r
+// Distributed under the MIT License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"runtime"
+	"strconv"
+	"time"
+)
+
+const serveSource = "(request)"
+
+// serveMaxDpi is the largest resolution a request may ask for.
+const serveMaxDpi = 300
+
+var (
+	serveListen  = "127.0.0.1:8080"
+	serveMaxSize = int64(1 << 20)
+	serveTimeout = int64(10)
+	serveMaxJobs = int64(runtime.NumCPU())
+)
+
+var serveArgSpecList = []argInfo{
+	argInfo{"--listen", argStr, argSetStr(&serveListen), " Specify the address to listen on (default: 127.0.0.1:8080)"},
+	argInfo{"--max-request-size", argInt, argSetInt(&serveMaxSize), " Set the size limit of a request in bytes (default: 1048576)"},
+	argInfo{"--timeout", argInt, argSetInt(&serveTimeout), " Set the time limit of a request in seconds (default: 10)"},
+	argInfo{"--max-jobs", argInt, argSetInt(&serveMaxJobs), " Set the number of renderings run at once (default: number of CPUs)"},
+}
+
+type serveErrorResponse struct {
+	Error sceDiagnostic `json:"error"`
+}
+
+// serveHandler renders the documents posted to it. Each request has
+// its own job; the handler only holds the limits.
+type serveHandler struct {
+	maxSize int64
+	timeout time.Duration
+	slots   chan struct{} // limits the renderings run at once
+}
+
+func runServe(args []string) {
+	argParseArgs(args, serveArgSpecList, func(arg string) error {
+		return fmt.Errorf("serve takes no input file ('%s')", arg)
+	})
+	if serveMaxSize <= 0 || serveTimeout <= 0 || serveMaxJobs <= 0 {
+		scePanic(errors.New("limits of serve must be positive."))
+	}
+	h := &serveHandler{
+		maxSize: serveMaxSize,
+		timeout: time.Duration(serveTimeout) * time.Second,
+		slots:   make(chan struct{}, serveMaxJobs),
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/render", h)
+	srv := &http.Server{
+		Addr:         serveListen,
+		Handler:      mux,
+		ReadTimeout:  h.timeout,
+		WriteTimeout: 2 * h.timeout,
+	}
+	fmt.Printf("  %s version %s serving on http://%s/render\n", progName, version, serveListen)
+	sceAssert(srv.ListenAndServe())
+}
+
+func (h *serveHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	status := h.serve(w, r)
+	fmt.Printf("[%s] %s %s %d (%s)\n", start.Format("15:04:05"),
+		r.Method, r.URL.RequestURI(), status, time.Since(start).Round(time.Millisecond))
+}
+
+// serve handles a request and returns the status code of the response.
+func (h *serveHandler) serve(w http.ResponseWriter, r *http.Request) int {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		return serveError(w, http.StatusMethodNotAllowed,
+			errors.New("the source must be POSTed."))
+	}
+	src, err := ioutil.ReadAll(io.LimitReader(r.Body, h.maxSize+1))
+	if err != nil {
+		return serveError(w, http.StatusBadRequest, err)
+	}
+	if int64(len(src)) > h.maxSize {
+		return serveError(w, http.StatusRequestEntityTooLarge,
+			fmt.Errorf("the source exceeds %d bytes.", h.maxSize))
+	}
+	j, err := serveJobFor(r, string(src))
+	if err != nil {
+		return serveError(w, http.StatusBadRequest, err)
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), h.timeout)
+	defer cancel()
+	select {
+	case h.slots <- struct{}{}:
+	case <-ctx.Done():
+		return serveError(w, http.StatusServiceUnavailable,
+			errors.New("the server is too busy."))
+	}
+	type result struct {
+		data []byte
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		// the slot is kept until the rendering really ends
+		defer func() { <-h.slots }()
+		var res result
+		res.err = sceCatch(func() {
+			j.typeCheck()
+			res.data = j.render()
+		})
+		done <- res
+	}()
+	select {
+	case res := <-done:
+		if res.err != nil && sceIsInternal(res.err) {
+			return serveError(w, http.StatusInternalServerError, res.err)
+		} else if res.err != nil {
+			return serveError(w, http.StatusUnprocessableEntity, res.err)
+		}
+		w.Header().Set("Content-Type", j.mediaType())
+		w.Header().Set("Content-Length", strconv.Itoa(len(res.data)))
+		w.WriteHeader(http.StatusOK)
+		w.Write(res.data)
+		return http.StatusOK
+	case <-ctx.Done():
+		return serveError(w, http.StatusGatewayTimeout,
+			errors.New("the rendering did not finish in time."))
+	}
+}
+
+// serveJobFor makes the job for a request, whose settings are given by
+// the query parameters: mode, muffler, markdown, dpi, transparent and
+// highlight. Templates and includes are not available, since they are
+// files.
+func serveJobFor(r *http.Request, src string) (*scJob, error) {
+	q := r.URL.Query()
+	j := newJob()
+	j.evalTexts[serveSource] = src
+	j.sources = []string{serveSource}
+	j.inFile = serveSource
+	j.noInclude = true
+
+	mode := q.Get("mode")
+	if mode == "pdf" {
+		mode = ""
+	}
+	if _, ok := textModeMediaType[mode]; !ok {
+		return nil, fmt.Errorf("unknown text mode value '%s'", mode)
+	}
+	j.textMode = mode
+	if val := q.Get("muffler"); val != "" {
+		if err := j.setMuffler(val); err != nil {
+			return nil, err
+		}
+	}
+	if val := q.Get("dpi"); val != "" {
+		dpi, err := strconv.ParseInt(val, 10, 64)
+		if err != nil || dpi <= 0 || dpi > serveMaxDpi {
+			return nil, fmt.Errorf("illegal dpi value (%s); it must be 1 to %d.", val, serveMaxDpi)
+		}
+		j.dpi = dpi
+	}
+	j.markdown = serveFlag(q.Get("markdown"))
+	j.transparent = serveFlag(q.Get("transparent"))
+	j.highlight = q.Get("highlight")
+	if err := j.check(); err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+func serveFlag(val string) bool {
+	return val != "" && val != "0" && val != "false"
+}
+
+func serveError(w http.ResponseWriter, status int, err error) int {
+	buf := new(bytes.Buffer)
+	enc := json.NewEncoder(buf)
+	enc.SetIndent("", "  ")
+	sceAssert(enc.Encode(serveErrorResponse{sceDiagnosticFor(err)}))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(buf.Bytes())
+	return status
+}
@@ -0,0 +1,76 @@
+// Copyright (c) 2018-2021 Takayuki YATO (aka. "ZR")
+//   GitHub:   https://github.com/zr-tex8r
+//   Twitter:  @zr_tex8r
+// Distributed under the MIT License.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+
+	"github.com/zr-tex8r/xcolor"
+)
+
+// scsMufflerHtmlColor returns the HTML color code of the muffler.
+func scsMufflerHtmlColor(val string) string {
+	col, err := xcolor.Parse(val)
+	if err != nil {
+		scePanic(err)
+	}
+	return col.HtmlCode()
+}
+
+// scsPathData returns the SVG path data for a shape in the unit square.
+func scsPathData(shape scgShape) string {
+	buf := new(bytes.Buffer)
+	for _, seg := range shape.segs {
+		if buf.Len() > 0 {
+			buf.WriteByte(' ')
+		}
+		buf.WriteByte(seg.op - 'a' + 'A') // M, L, C
+		for _, p := range seg.pts {
+			fmt.Fprintf(buf, " %s %s", scgReal(p.x), scgReal(p.y))
+		}
+	}
+	if shape.closed {
+		buf.WriteString(" Z")
+	}
+	return buf.String()
+}
+
+// makeSnowmanSvg returns an SVG image of the figure, without the XML
+// declaration; width and height may be empty.
+func makeSnowmanSvg(muffler, width, height string) string {
+	buf := new(bytes.Buffer)
+	buf.WriteString(`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 1 1"`)
+	if width != "" {
+		fmt.Fprintf(buf, ` width="%s"`, width)
+	}
+	if height != "" {
+		fmt.Fprintf(buf, ` height="%s"`, height)
+	}
+	buf.WriteString(` role="img" aria-label="snowman">` + "\n")
+	fmt.Fprintf(buf, `<g transform="matrix(1 0 0 -1 0 1)" stroke-width="%s"`+
+		` stroke-linejoin="round" stroke-linecap="round">`+"\n",
+		strconv.FormatFloat(scgLineWidth, 'f', -1, 64))
+	mcol := scsMufflerHtmlColor(muffler)
+	for _, shape := range scgShapes {
+		col := "#000000"
+		if shape.muffler {
+			col = mcol
+		}
+		fill, stroke := "none", "none"
+		if shape.fill {
+			fill = col
+		}
+		if shape.stroke {
+			stroke = col
+		}
+		fmt.Fprintf(buf, `<path fill="%s" stroke="%s" d="%s"/>`+"\n",
+			fill, stroke, scsPathData(shape))
+	}
+	buf.WriteString("</g>\n</svg>\n")
+	return buf.String()
+}
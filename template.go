@@ -0,0 +1,82 @@
+// Copyright (c) 2018-2021 Takayuki YATO (aka. "ZR")
+//   GitHub:   https://github.com/zr-tex8r
+//   Twitter:  @zr_tex8r
+// Distributed under the MIT License.
+
+package main
+
+import (
+	"bytes"
+	htmltemplate "html/template"
+	"io/ioutil"
+	"path/filepath"
+	"text/template"
+
+	"github.com/zr-tex8r/xcolor"
+)
+
+// sctTemplateData is the data model given to user templates.
+type sctTemplateData struct {
+	// Snowman is the markup of the essential content; an inline SVG for
+	// html mode and the snowman elements for xml mode.
+	Snowman htmltemplate.HTML
+	Muffler sctTemplateMuffler
+	// Metadata has the keys "title", "creator" and "lang".
+	Metadata map[string]string
+	// Source is the name of the input file.
+	Source  string
+	Version string
+}
+
+type sctTemplateMuffler struct {
+	Spec  string // as given by --muffler
+	Model string // "gray", "rgb" or "cmyk"
+	Html  string // such as "#FF0000"
+}
+
+// sctFindTemplate resolves the template file name along the
+// configuration search path.
+func sctFindTemplate(j *scJob) string {
+	path, err := cfgFindFile(j, j.template, "templates")
+	sceAssert(err)
+	return path
+}
+
+func sctTemplateDataFor(j *scJob, snowman string) *sctTemplateData {
+	col, err := xcolor.Parse(j.mufflerVal)
+	if err != nil {
+		scePanic(err)
+	}
+	return &sctTemplateData{
+		Snowman: htmltemplate.HTML(snowman),
+		Muffler: sctTemplateMuffler{j.mufflerVal, col.Model().String(), col.HtmlCode()},
+		Metadata: map[string]string{
+			"title":   docTitle,
+			"creator": progName,
+			"lang":    docLang,
+		},
+		Source:  ordInPath(j.inFile),
+		Version: version,
+	}
+}
+
+// sctApplyTemplate executes the user template, with html/template if
+// isHtml is true and text/template otherwise.
+func sctApplyTemplate(j *scJob, isHtml bool, snowman string) string {
+	path := sctFindTemplate(j)
+	src, err := ioutil.ReadFile(path)
+	sceAssert(err)
+	data := sctTemplateDataFor(j, snowman)
+	name := filepath.Base(path)
+	buf := new(bytes.Buffer)
+	if isHtml {
+		tmpl, err := htmltemplate.New(name).Parse(string(src))
+		sceAssert(err)
+		sceAssert(tmpl.Execute(buf, data))
+	} else {
+		tmpl, err := template.New(name).Parse(string(src))
+		sceAssert(err)
+		sceAssert(tmpl.Execute(buf, data))
+	}
+	return buf.String()
+}
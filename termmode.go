@@ -0,0 +1,114 @@
+// Copyright (c) 2018-2021 Takayuki YATO (aka. "ZR")
+//   GitHub:   https://github.com/zr-tex8r
+//   Twitter:  @zr_tex8r
+// Distributed under the MIT License.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image/color"
+	"os"
+	"strings"
+)
+
+type sctTermDepth int
+
+const (
+	sctTermNoColor = sctTermDepth(iota)
+	sctTerm256
+	sctTermTrue
+)
+
+// The parts of sctSnowman that form the muffler, given as the
+// characters in each line of the art.
+var sctMufflerChars = map[int]string{
+	4: "#",
+	5: `*"`,
+}
+
+// sctTermColorDepth decides how colorful the output to stdout can be.
+func sctTermColorDepth() sctTermDepth {
+	if _, ok := os.LookupEnv("NO_COLOR"); ok {
+		return sctTermNoColor
+	}
+	if fi, err := os.Stdout.Stat(); err != nil || fi.Mode()&os.ModeCharDevice == 0 {
+		return sctTermNoColor
+	}
+	if os.Getenv("TERM") == "dumb" {
+		return sctTermNoColor
+	}
+	switch os.Getenv("COLORTERM") {
+	case "truecolor", "24bit":
+		return sctTermTrue
+	}
+	return sctTerm256
+}
+
+// sctAnsiColor returns the SGR sequence that sets the foreground color.
+func sctAnsiColor(c color.Color, depth sctTermDepth) string {
+	r, g, b, _ := c.RGBA()
+	r, g, b = r>>8, g>>8, b>>8
+	if depth == sctTermTrue {
+		return fmt.Sprintf("\x1b[38;2;%d;%d;%dm", r, g, b)
+	}
+	return fmt.Sprintf("\x1b[38;5;%dm", sctAnsi256(r, g, b))
+}
+
+// sctAnsi256 finds the nearest color in the xterm 256-color palette,
+// among the color cube and the gray ramp.
+func sctAnsi256(r, g, b uint32) int {
+	levels := []uint32{0, 95, 135, 175, 215, 255}
+	near := func(v uint32) int {
+		k := 0
+		for i, l := range levels {
+			if sctSqDist(v, l) < sctSqDist(v, levels[k]) {
+				k = i
+			}
+		}
+		return k
+	}
+	cr, cg, cb := near(r), near(g), near(b)
+	code := 16 + 36*cr + 6*cg + cb
+	dist := sctSqDist(r, levels[cr]) + sctSqDist(g, levels[cg]) + sctSqDist(b, levels[cb])
+	gi := (int((r+g+b)/3) - 3) / 10
+	if gi < 0 {
+		gi = 0
+	} else if gi > 23 {
+		gi = 23
+	}
+	gv := uint32(8 + 10*gi)
+	if sctSqDist(r, gv)+sctSqDist(g, gv)+sctSqDist(b, gv) < dist {
+		code = 232 + gi
+	}
+	return code
+}
+
+func sctSqDist(a, b uint32) uint32 {
+	d := int(a) - int(b)
+	return uint32(d * d)
+}
+
+func (j *scJob) makeTermText() string {
+	depth := j.termDepth
+	if depth == sctTermNoColor {
+		return sctSnowman
+	}
+	esc, reset := sctAnsiColor(j.mufflerColor, depth), "\x1b[0m"
+	buf := new(bytes.Buffer)
+	for ln, line := range strings.SplitAfter(sctSnowman, "\n") {
+		chars, colored := sctMufflerChars[ln], false
+		for _, r := range line {
+			in := chars != "" && strings.ContainsRune(chars, r)
+			if in && !colored {
+				buf.WriteString(esc)
+			} else if !in && colored {
+				buf.WriteString(reset)
+			}
+			colored = in
+			buf.WriteRune(r)
+		}
+	}
+	return buf.String()
+}
@@ -6,17 +6,20 @@
 package main
 
 import (
+	"bytes"
 	_ "errors"
-	_ "fmt"
+	"fmt"
+	"html"
+
 	"github.com/zr-tex8r/xcolor"
 )
 
-func sctHtmlMufflerColor() string {
-	if mufflerVal == dfltMuffler {
+func sctHtmlMufflerColor(val string) string {
+	if val == dfltMuffler {
 		return ""
 	}
 
-	col, err := xcolor.Parse(mufflerVal)
+	col, err := xcolor.Parse(val)
 	if err != nil {
 		scePanic(err)
 	}
@@ -36,24 +39,32 @@ const sctSnowman = "     " + // HURRAY!
    \_______/        
 `
 
-func makePlainText() string {
+func (j *scJob) makePlainText() string {
 	return sctSnowman
 }
 
 //-------- HTML
 
-const sctHtmlPrologue = // something HTML5
+const sctHtmlPrologue = // HTML5, for real
 `<!DOCTYPE html>
-<html>
+<html lang="%[1]s">
 <head>
 <meta charset="UTF-8">
-</head>
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="generator" content="%[2]s">
+<title>%[3]s</title>
 <style>
 body {
-font-size: 480px;
-font-family: "SCAlleSnowman", sans-serif;
+margin: 0;
+}
+body > svg {
+display: block;
+width: 100vmin;
+height: 100vmin;
+margin: 0 auto;
 }
 </style>
+</head>
 <body>
 `
 
@@ -62,17 +73,61 @@ const sctHtmlEpilogue = //
 </html>
 `
 
-func makeHtmlText() string {
-	snowman := "&#x2603;\n"
-	return sctHtmlPrologue + snowman + sctHtmlEpilogue
+func (j *scJob) makeHtmlText() string {
+	// the muffler color is given in the SVG itself
+	snowman := makeSnowmanSvg(j.mufflerVal, "", "")
+	if j.template != "" {
+		return sctApplyTemplate(j, true, snowman)
+	}
+	prologue := fmt.Sprintf(sctHtmlPrologue, docLang,
+		html.EscapeString(progName+" "+version), html.EscapeString(docTitle))
+	return prologue + snowman + sctHtmlEpilogue
 }
 
 //-------- XML
 
-func makeXmlText() string {
-	col := sctHtmlMufflerColor()
+// The namespace of the XML output; the version number is bumped when
+// the document structure changes.
+const (
+	sctXmlNamespace = "https://github.com/zr-tex8r/scsatysfi/ns/1"
+	sctXmlVersion   = "1"
+)
+
+const sctXmlPrologue = //
+`<?xml version="1.0" encoding="UTF-8"?>
+<document xmlns="%s" version="%s" type="%s" generator="%s" generator-version="%s">
+<metadata>
+<title>%s</title>
+<creator>%s</creator>
+<lang>%s</lang>
+%s</metadata>
+`
+
+const sctXmlEpilogue = //
+`</document>
+`
+
+func (j *scJob) makeXmlText() string {
+	col := sctHtmlMufflerColor(j.mufflerVal)
 	if col != "" {
-		col = "muffler=\"" + col + "\""
+		col = " muffler=\"" + col + "\""
+	}
+	buf := new(bytes.Buffer)
+	for _, p := range j.value.snowmen {
+		fmt.Fprintf(buf, "<snowman source=\"%s\" line=\"%d\" column=\"%d\"%s/>\n",
+			html.EscapeString(ordInPath(p.src)), p.line, p.col, col)
+	}
+	snowmen := buf.String()
+	if j.template != "" {
+		return sctApplyTemplate(j, false, snowmen)
+	}
+	esc := html.EscapeString
+	sources := ""
+	for _, psrc := range j.sources {
+		sources += "<source>" + esc(ordInPath(psrc)) + "</source>\n"
 	}
-	return "<snowman " + col + "/>\n"
+	prologue := fmt.Sprintf(sctXmlPrologue, sctXmlNamespace, sctXmlVersion,
+		j.value.vtype, esc(progName), esc(version),
+		esc(docTitle), esc(progName), docLang, sources)
+	return prologue + snowmen + sctXmlEpilogue
 }
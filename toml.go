@@ -0,0 +1,476 @@
+// Copyright (c) 2018-2021 Takayuki YATO (aka. "ZR")
+//   GitHub:   https://github.com/zr-tex8r
+//   Twitter:  @zr_tex8r
+// Distributed under the MIT License.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// A reader of the subset of TOML used by the project manifest. It has
+// tables, arrays of tables, dotted keys, inline tables and arrays, and
+// the values are basic and literal strings, integers and booleans.
+// Multi-line strings, floats and dates are not supported.
+
+// tmlTable is a table; the values are string, int64, bool, tmlTable,
+// []interface{} (arrays) and []tmlTable (arrays of tables).
+type tmlTable map[string]interface{}
+
+type tmlError struct {
+	err error
+}
+
+type tmlParser struct {
+	src  string // the name in diagnostics
+	text string
+	pos  int
+	line int
+	lpos int // the position of the line start
+	kpos int // the position of the key being read, for its errors
+	// how each table, named by its path, was made
+	tables map[string]tmlHow
+}
+
+type tmlHow int
+
+const (
+	tmlImplicit tmlHow = iota // as a parent in a header
+	tmlHeader
+	tmlDotted
+	tmlInline
+)
+
+func tmlParse(src string, r io.Reader) (tbl tmlTable, err error) {
+	bs, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			e, ok := r.(tmlError)
+			if !ok {
+				panic(r)
+			}
+			tbl, err = nil, e.err
+		}
+	}()
+	p := &tmlParser{src: src, text: string(bs), line: 1, tables: make(map[string]tmlHow)}
+	return p.parse(), nil
+}
+
+func (p *tmlParser) fail(format string, args ...interface{}) {
+	col := p.pos - p.lpos
+	panic(tmlError{sceTomlError(p.src, p.line, col, fmt.Sprintf(format, args...))})
+}
+
+// failKey fails at the key being read.
+func (p *tmlParser) failKey(format string, args ...interface{}) {
+	p.pos = p.kpos
+	p.fail(format, args...)
+}
+
+func (p *tmlParser) eof() bool {
+	return p.pos >= len(p.text)
+}
+
+func (p *tmlParser) peek() byte {
+	if p.eof() {
+		return 0
+	}
+	return p.text[p.pos]
+}
+
+func (p *tmlParser) next() byte {
+	c := p.peek()
+	p.pos++
+	if c == '\n' {
+		p.line++
+		p.lpos = p.pos
+	}
+	return c
+}
+
+func (p *tmlParser) expect(c byte) {
+	if p.eof() || p.peek() != c {
+		p.fail("expected '%c'", c)
+	}
+	p.next()
+}
+
+func (p *tmlParser) skipSpace() {
+	for !p.eof() && (p.peek() == ' ' || p.peek() == '\t') {
+		p.pos++
+	}
+}
+
+func (p *tmlParser) skipComment() {
+	if p.peek() == '#' {
+		for !p.eof() && p.peek() != '\n' {
+			p.pos++
+		}
+	}
+}
+
+// skipBlank skips spaces, comments and newlines.
+func (p *tmlParser) skipBlank() {
+	for {
+		p.skipSpace()
+		p.skipComment()
+		if p.peek() != '\n' && p.peek() != '\r' {
+			return
+		}
+		p.next()
+	}
+}
+
+func (p *tmlParser) endLine() {
+	p.skipSpace()
+	p.skipComment()
+	if p.peek() == '\r' {
+		p.next()
+	}
+	if !p.eof() {
+		if p.peek() != '\n' {
+			p.fail("unexpected '%c' after a value", p.peek())
+		}
+		p.next()
+	}
+}
+
+func (p *tmlParser) parse() tmlTable {
+	root := tmlTable{}
+	cur, path := root, ""
+	for {
+		p.skipBlank()
+		if p.eof() {
+			return root
+		}
+		if p.peek() == '[' {
+			p.next()
+			array := (p.peek() == '[')
+			if array {
+				p.next()
+			}
+			p.skipSpace()
+			p.kpos = p.pos
+			keys := p.parseKey()
+			p.expect(']')
+			if array {
+				p.expect(']')
+			}
+			cur, path = p.openTable(root, keys, array)
+		} else {
+			p.kpos = p.pos
+			keys := p.parseKey()
+			p.expect('=')
+			p.skipSpace()
+			p.setValue(cur, path, keys, p.parseValue())
+		}
+		p.endLine()
+	}
+}
+
+// parseKey reads a dotted key, with the spaces around it.
+func (p *tmlParser) parseKey() (keys []string) {
+	for {
+		p.skipSpace()
+		switch c := p.peek(); {
+		case c == '"':
+			keys = append(keys, p.parseBasicString())
+		case c == '\'':
+			keys = append(keys, p.parseLiteralString())
+		default:
+			start := p.pos
+			for !p.eof() && tmlIsBareKeyChar(p.peek()) {
+				p.pos++
+			}
+			if p.pos == start {
+				p.fail("expected a key")
+			}
+			keys = append(keys, p.text[start:p.pos])
+		}
+		p.skipSpace()
+		if p.peek() != '.' {
+			return
+		}
+		p.next()
+	}
+}
+
+func tmlIsBareKeyChar(c byte) bool {
+	return 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' ||
+		'0' <= c && c <= '9' || c == '_' || c == '-'
+}
+
+// descend returns the table under the key and its path, making it if
+// absent. A header goes into the last table of an array of tables;
+// dotted keys (!header) go only into the tables they have made.
+func (p *tmlParser) descend(t tmlTable, path, key string, header bool) (tmlTable, string) {
+	path += "." + strconv.Quote(key)
+	how := tmlDotted
+	if header {
+		how = tmlImplicit
+	}
+	switch v := t[key].(type) {
+	case nil:
+		sub := tmlTable{}
+		t[key] = sub
+		p.tables[path] = how
+		return sub, path
+	case tmlTable:
+		switch p.tables[path] {
+		case tmlInline:
+			p.failKey("inline table '%s' cannot be extended", key)
+		case tmlDotted:
+			if header {
+				return v, path
+			}
+		default:
+			if !header {
+				p.failKey("table '%s' cannot be extended by dotted keys", key)
+			}
+		}
+		return v, path
+	case []tmlTable:
+		if !header {
+			p.failKey("array of tables '%s' cannot be extended by dotted keys", key)
+		}
+		return v[len(v)-1], fmt.Sprintf("%s#%d", path, len(v)-1)
+	}
+	p.failKey("key '%s' is not a table", key)
+	return nil, ""
+}
+
+func (p *tmlParser) openTable(root tmlTable, keys []string, array bool) (tmlTable, string) {
+	t, path := root, ""
+	for _, key := range keys[:len(keys)-1] {
+		t, path = p.descend(t, path, key, true)
+	}
+	last := keys[len(keys)-1]
+	if !array {
+		_, ok := t[last]
+		t, path = p.descend(t, path, last, true)
+		if ok && p.tables[path] != tmlImplicit {
+			p.failKey("table '%s' is defined twice", last)
+		}
+		p.tables[path] = tmlHeader
+		return t, path
+	}
+	sub := tmlTable{}
+	path += "." + strconv.Quote(last)
+	switch v := t[last].(type) {
+	case nil:
+		t[last] = []tmlTable{sub}
+	case []tmlTable:
+		t[last] = append(v, sub)
+	default:
+		p.failKey("key '%s' is not an array of tables", last)
+	}
+	path = fmt.Sprintf("%s#%d", path, len(t[last].([]tmlTable))-1)
+	p.tables[path] = tmlHeader
+	return sub, path
+}
+
+// setValue sets the value under the dotted key in the table t at path.
+func (p *tmlParser) setValue(t tmlTable, path string, keys []string, v interface{}) {
+	for _, key := range keys[:len(keys)-1] {
+		t, path = p.descend(t, path, key, false)
+	}
+	last := keys[len(keys)-1]
+	if _, ok := t[last]; ok {
+		p.failKey("duplicate key '%s'", last)
+	}
+	t[last] = v
+	if _, ok := v.(tmlTable); ok {
+		p.tables[path+"."+strconv.Quote(last)] = tmlInline
+	}
+}
+
+func (p *tmlParser) parseValue() interface{} {
+	switch c := p.peek(); {
+	case strings.HasPrefix(p.text[p.pos:], `"""`), strings.HasPrefix(p.text[p.pos:], `'''`):
+		p.fail("multi-line strings are not supported")
+	case c == '"':
+		return p.parseBasicString()
+	case c == '\'':
+		return p.parseLiteralString()
+	case c == '[':
+		return p.parseArray()
+	case c == '{':
+		return p.parseInlineTable()
+	case strings.HasPrefix(p.text[p.pos:], "true"):
+		p.pos += 4
+		return true
+	case strings.HasPrefix(p.text[p.pos:], "false"):
+		p.pos += 5
+		return false
+	case c == '+' || c == '-' || '0' <= c && c <= '9':
+		return p.parseInteger()
+	}
+	p.fail("expected a value")
+	return nil
+}
+
+func (p *tmlParser) parseBasicString() string {
+	p.expect('"')
+	var sb strings.Builder
+	for {
+		if p.eof() || p.peek() == '\n' {
+			p.fail("unterminated string")
+		}
+		c := p.next()
+		switch c {
+		case '"':
+			return sb.String()
+		case '\\':
+			switch e := p.next(); e {
+			case 'b':
+				sb.WriteByte('\b')
+			case 't':
+				sb.WriteByte('\t')
+			case 'n':
+				sb.WriteByte('\n')
+			case 'f':
+				sb.WriteByte('\f')
+			case 'r':
+				sb.WriteByte('\r')
+			case '"', '\\':
+				sb.WriteByte(e)
+			case 'u', 'U':
+				n := 4
+				if e == 'U' {
+					n = 8
+				}
+				if p.pos+n > len(p.text) {
+					p.fail("bad escape sequence")
+				}
+				v, err := strconv.ParseUint(p.text[p.pos:p.pos+n], 16, 32)
+				if err != nil || !utf8.ValidRune(rune(v)) {
+					p.fail("bad escape sequence")
+				}
+				p.pos += n
+				sb.WriteRune(rune(v))
+			default:
+				p.fail("bad escape sequence")
+			}
+		default:
+			sb.WriteByte(c)
+		}
+	}
+}
+
+func (p *tmlParser) parseLiteralString() string {
+	p.expect('\'')
+	start := p.pos
+	for p.peek() != '\'' {
+		if p.eof() || p.peek() == '\n' {
+			p.fail("unterminated string")
+		}
+		p.pos++
+	}
+	s := p.text[start:p.pos]
+	p.next()
+	return s
+}
+
+func (p *tmlParser) parseArray() []interface{} {
+	p.expect('[')
+	vs := []interface{}{}
+	for {
+		p.skipBlank()
+		if p.peek() == ']' {
+			p.next()
+			return vs
+		}
+		vs = append(vs, p.parseValue())
+		p.skipBlank()
+		switch p.peek() {
+		case ',':
+			p.next()
+		case ']':
+			p.next()
+			return vs
+		default:
+			p.fail("expected ',' or ']'")
+		}
+	}
+}
+
+func (p *tmlParser) parseInlineTable() tmlTable {
+	p.expect('{')
+	t, path := tmlTable{}, fmt.Sprintf("{%d}", p.pos)
+	p.skipSpace()
+	if p.peek() == '}' {
+		p.next()
+		return t
+	}
+	for {
+		p.kpos = p.pos
+		keys := p.parseKey()
+		p.expect('=')
+		p.skipSpace()
+		p.setValue(t, path, keys, p.parseValue())
+		p.skipSpace()
+		switch p.peek() {
+		case ',':
+			p.next()
+		case '}':
+			p.next()
+			return t
+		default:
+			p.fail("expected ',' or '}'")
+		}
+	}
+}
+
+func (p *tmlParser) parseInteger() int64 {
+	start := p.pos
+	for !p.eof() && strings.IndexByte("+-_.:0123456789abcdefABCDEFoxOX", p.peek()) >= 0 {
+		p.pos++
+	}
+	tok := p.text[start:p.pos]
+	if strings.ContainsAny(tok, ".:eE") && !strings.HasPrefix(strings.TrimLeft(tok, "+-"), "0x") {
+		p.fail("floats and dates are not supported")
+	}
+	v, ok := tmlParseInteger(tok)
+	if !ok {
+		p.pos = start
+		p.fail("bad integer '%s'", tok)
+	}
+	return v
+}
+
+// tmlParseInteger reads an integer as TOML does: a decimal one with
+// an optional sign and no leading zeros, or an unsigned one prefixed by
+// 0x, 0o or 0b. An underscore must be between digits.
+func tmlParseInteger(tok string) (int64, bool) {
+	sign, digits, base := "", tok, 10
+	switch {
+	case strings.HasPrefix(tok, "0x"):
+		digits, base = tok[2:], 16
+	case strings.HasPrefix(tok, "0o"):
+		digits, base = tok[2:], 8
+	case strings.HasPrefix(tok, "0b"):
+		digits, base = tok[2:], 2
+	default:
+		if strings.HasPrefix(tok, "+") || strings.HasPrefix(tok, "-") {
+			sign, digits = tok[:1], tok[1:]
+		}
+		if len(digits) > 1 && digits[0] == '0' {
+			return 0, false
+		}
+	}
+	if digits == "" || digits[0] == '_' || digits[len(digits)-1] == '_' ||
+		strings.Contains(digits, "__") || strings.ContainsAny(digits, "+-") {
+		return 0, false
+	}
+	v, err := strconv.ParseInt(sign+strings.Replace(digits, "_", "", -1), base, 64)
+	return v, err == nil
+}
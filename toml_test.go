@@ -0,0 +1,118 @@
+// Copyright (c) 2018-2021 Takayuki YATO (aka. "ZR")
+//   GitHub:   https://github.com/zr-tex8r
+//   Twitter:  @zr_tex8r
+// Distributed under the MIT License.
+
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTmlInteger(t *testing.T) {
+	tests := []struct {
+		tok string
+		v   int64
+		ok  bool
+	}{
+		// decimal
+		{"0", 0, true},
+		{"+0", 0, true},
+		{"-0", 0, true},
+		{"17", 17, true},
+		{"+17", 17, true},
+		{"-17", -17, true},
+		{"1_000", 1000, true},
+		{"9223372036854775807", 9223372036854775807, true},
+		{"017", 0, false},
+		{"-017", 0, false},
+		{"00", 0, false},
+		{"1__000", 0, false},
+		{"1_", 0, false},
+		{"+_1", 0, false},
+		{"--1", 0, false},
+		{"9223372036854775808", 0, false},
+		// hexadecimal
+		{"0xff", 255, true},
+		{"0xDEAD_beef", 0xdeadbeef, true},
+		{"0x", 0, false},
+		{"0x_ff", 0, false},
+		{"0xff_", 0, false},
+		{"0xf__f", 0, false},
+		{"0x+1", 0, false},
+		{"+0xff", 0, false},
+		{"-0xff", 0, false},
+		{"0Xff", 0, false},
+		// octal
+		{"0o17", 15, true},
+		{"0o1_7", 15, true},
+		{"0o", 0, false},
+		{"0o_17", 0, false},
+		{"0o17_", 0, false},
+		{"0o8", 0, false},
+		{"-0o17", 0, false},
+		// binary
+		{"0b101", 5, true},
+		{"0b1_01", 5, true},
+		{"0b", 0, false},
+		{"0b_1", 0, false},
+		{"0b1_", 0, false},
+		{"0b1__0", 0, false},
+		{"0b2", 0, false},
+		{"+0b1", 0, false},
+	}
+	for _, tt := range tests {
+		tbl, err := tmlParse("(test)", strings.NewReader("v = "+tt.tok+"\n"))
+		if !tt.ok {
+			if err == nil {
+				t.Errorf("%s: got %v; want an error", tt.tok, tbl["v"])
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.tok, err)
+		} else if v, _ := tbl["v"].(int64); v != tt.v {
+			t.Errorf("%s: got %v; want %d", tt.tok, tbl["v"], tt.v)
+		}
+	}
+}
+
+func TestTmlTables(t *testing.T) {
+	tests := []struct {
+		text string
+		col  int // of the error, or -1
+	}{
+		{"[a.b]\n[a]", -1},
+		{"[a]\nb.c = 1\n[a.b.d]", -1},
+		{"[[a]]\n[a.b]\n[[a]]\n[a.b]", -1},
+		{"a = {b.c = 1, b.d = 2}", -1},
+		{"[a]\n[a]", 1},
+		{"[a]\n[ b ]\n[ a ]", 2},
+		{"[a.b]\nx = 1\n[a.b]", 1},
+		{"a.b = 1\n[a]", 1},
+		{"[a]\nb.c = 1\n[a.b]", 1},
+		{"a = {}\n[a]", 1},
+		{"a = {}\na.b = 1", 0},
+		{"[a.b.c]\n[a]\nb.c.d = 1", 0},
+		{"[[a]]\na.b = 1", -1},
+		{"[[t.a]]\n[t]\na.b = 1", 0},
+		{"[[t.a]]\n[t]\nx = 1\n  a.b.c = 1", 2},
+		{"[[a]]\n[a]", 1},
+		{"a = [{}]\n[[a]]", 2},
+	}
+	for _, tt := range tests {
+		_, err := tmlParse("(test)", strings.NewReader(tt.text))
+		if tt.col < 0 {
+			if err != nil {
+				t.Errorf("%q: %v", tt.text, err)
+			}
+			continue
+		}
+		e, ok := err.(*sceError)
+		line := strings.Count(tt.text, "\n") + 1
+		if !ok || e.pos == nil || e.pos.line != line || e.pos.col != tt.col {
+			t.Errorf("%q: got %v; want an error at %d:%d", tt.text, err, line, tt.col)
+		}
+	}
+}
@@ -7,6 +7,7 @@ package main
 
 import (
 	"path/filepath"
+	"strings"
 )
 
 func unxFullPath(path string) string {
@@ -32,19 +33,25 @@ func ordPath(path string) string {
 }
 
 func ordInPath(path string) string {
-	if fullPath && evalVal == "" {
+	if fullPath && !isSyntheticSource(path) {
 		return natFullPath(path)
 	}
 	return filepath.Base(path)
 }
 
 func fullInPath(path string) string {
-	if evalVal == "" {
+	if !isSyntheticSource(path) {
 		return natFullPath(path)
 	}
 	return path
 }
 
+// isSyntheticSource tells whether the name denotes an input source that
+// is not a file, such as "(eval#1)"; such names are parenthesized.
+func isSyntheticSource(path string) bool {
+	return strings.HasPrefix(path, "(") && strings.HasSuffix(path, ")")
+}
+
 func changeExt(path, ext string) string {
 	s := path[:len(path)-len(filepath.Ext(path))]
 	return s + ext
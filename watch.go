@@ -0,0 +1,116 @@
+// Copyright (c) 2018-2021 Takayuki YATO (aka. "ZR")
+//   GitHub:   https://github.com/zr-tex8r
+//   Twitter:  @zr_tex8r
+// Distributed under the MIT License.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	watchInterval = 500 * time.Millisecond
+	// A change is processed after the files stay unchanged for this long.
+	watchDebounce = 300 * time.Millisecond
+)
+
+// watchStamp is what we know about a file; a missing file has the
+// zero value.
+type watchStamp struct {
+	mtime time.Time
+	size  int64
+}
+
+func watchStat(path string) watchStamp {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return watchStamp{}
+	}
+	return watchStamp{fi.ModTime(), fi.Size()}
+}
+
+func watchSnapshot(paths []string) map[string]watchStamp {
+	snap := make(map[string]watchStamp, len(paths))
+	for _, path := range paths {
+		snap[path] = watchStat(path)
+	}
+	return snap
+}
+
+// watchChanged returns the files whose state differs from the snapshot.
+func watchChanged(snap map[string]watchStamp) (paths []string) {
+	for path, st := range snap {
+		if watchStat(path) != st {
+			paths = append(paths, path)
+		}
+	}
+	return
+}
+
+// watchBuild runs a build and returns the files it depends on. Even if
+// the build fails, the output of the last successful one remains.
+func watchBuild(prev []string) []string {
+	j := newCliJob()
+	err := sceCatch(j.compile)
+	stamp := time.Now().Format("15:04:05")
+	if err != nil {
+		fmt.Print(sceDesc(err))
+		fmt.Printf("[%s] build failed; keeping the last output. waiting for changes ...\n", stamp)
+	} else {
+		fmt.Printf("[%s] build succeeded (%s). waiting for changes ...\n",
+			stamp, j.value.vtype)
+	}
+	// the files read so far, plus the ones known before
+	deps := append([]string(nil), j.deps...)
+	for _, path := range append(prev, j.sources...) {
+		if !j.isEvalSource(path) && !watchContains(deps, path) {
+			deps = append(deps, path)
+		}
+	}
+	return deps
+}
+
+func watchContains(paths []string, path string) bool {
+	for _, p := range paths {
+		if p == path {
+			return true
+		}
+	}
+	return false
+}
+
+func runWatch() {
+	deps := watchBuild(nil)
+	for {
+		before := watchSnapshot(deps)
+		for len(watchChanged(before)) == 0 {
+			time.Sleep(watchInterval)
+		}
+		// debounce: wait until the files settle
+		for {
+			snap := watchSnapshot(deps)
+			time.Sleep(watchDebounce)
+			if len(watchChanged(snap)) == 0 {
+				break
+			}
+		}
+		fmt.Printf("[%s] change detected in %s; rebuilding ...\n",
+			time.Now().Format("15:04:05"), watchNames(watchChanged(before)))
+		deps = watchBuild(deps)
+	}
+}
+
+func watchNames(paths []string) string {
+	var names []string
+	for _, path := range paths {
+		names = append(names, "'"+ordInPath(path)+"'")
+	}
+	if len(names) > 3 {
+		names = append(names[:3], "...")
+	}
+	return strings.Join(names, ", ")
+}
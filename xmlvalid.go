@@ -0,0 +1,453 @@
+// Copyright (c) 2018-2021 Takayuki YATO (aka. "ZR")
+//   GitHub:   https://github.com/zr-tex8r
+//   Twitter:  @zr_tex8r
+// Distributed under the MIT License.
+
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// The schema for xml mode; this must agree with schema/document.rng,
+// which TestScxSchemaFile checks.
+const scxSchema = //
+`<?xml version="1.0" encoding="UTF-8"?>
+<!-- RELAX NG schema for the output of scSATySFi with xml text mode. -->
+<grammar xmlns="http://relaxng.org/ns/structure/1.0"
+    ns="https://github.com/zr-tex8r/scsatysfi/ns/1"
+    datatypeLibrary="http://www.w3.org/2001/XMLSchema-datatypes">
+  <start>
+    <element name="document">
+      <attribute name="version"><value>1</value></attribute>
+      <attribute name="type">
+        <choice><value>nix</value><value>essential</value></choice>
+      </attribute>
+      <attribute name="generator"><text/></attribute>
+      <attribute name="generator-version"><text/></attribute>
+      <ref name="metadata"/>
+      <zeroOrMore><ref name="snowman"/></zeroOrMore>
+    </element>
+  </start>
+  <define name="metadata">
+    <element name="metadata">
+      <element name="title"><text/></element>
+      <element name="creator"><text/></element>
+      <element name="lang"><data type="language"/></element>
+      <oneOrMore>
+        <element name="source"><text/></element>
+      </oneOrMore>
+    </element>
+  </define>
+  <define name="snowman">
+    <element name="snowman">
+      <optional>
+        <attribute name="source"><text/></attribute>
+      </optional>
+      <attribute name="line"><data type="positiveInteger"/></attribute>
+      <attribute name="column"><data type="nonNegativeInteger"/></attribute>
+      <optional>
+        <attribute name="muffler">
+          <data type="string"><param name="pattern">#[0-9A-F]{6}</param></data>
+        </attribute>
+      </optional>
+      <empty/>
+    </element>
+  </define>
+</grammar>
+`
+
+// This is a validator for a small subset of RELAX NG (in the XML syntax),
+// which is enough for the schema above. Supported patterns are:
+// element, attribute, group, choice, optional, zeroOrMore, oneOrMore,
+// text, empty, value, data (with a pattern param) and ref.
+
+//--------scxNode
+
+// scxNode is a generic XML element, used both for schemas and instances.
+type scxNode struct {
+	space, local string
+	attrs        []xml.Attr
+	children     []*scxNode
+	text         string
+}
+
+func (n *scxNode) attr(name string) string {
+	val, _ := n.lookupAttr(name)
+	return val
+}
+
+// lookupAttr returns the attribute of the name, telling whether it is
+// present, for an empty value is a value.
+func (n *scxNode) lookupAttr(name string) (string, bool) {
+	for _, a := range n.attrs {
+		if a.Name.Space == "" && a.Name.Local == name {
+			return a.Value, true
+		}
+	}
+	return "", false
+}
+
+func scxParse(r io.Reader) (*scxNode, error) {
+	dec := xml.NewDecoder(r)
+	var stack []*scxNode
+	var root *scxNode
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			n := &scxNode{space: tok.Name.Space, local: tok.Name.Local}
+			for _, a := range tok.Attr {
+				if a.Name.Space != "xmlns" && a.Name.Local != "xmlns" {
+					n.attrs = append(n.attrs, a)
+				}
+			}
+			if len(stack) > 0 {
+				p := stack[len(stack)-1]
+				p.children = append(p.children, n)
+			} else {
+				root = n
+			}
+			stack = append(stack, n)
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			if len(stack) > 0 {
+				stack[len(stack)-1].text += string(tok)
+			}
+		}
+	}
+	if root == nil {
+		return nil, fmt.Errorf("no root element")
+	}
+	return root, nil
+}
+
+//--------scxSchemaInfo
+
+type scxGrammar struct {
+	start   *scxNode
+	defines map[string]*scxNode
+}
+
+func scxCompile(src string) (*scxGrammar, error) {
+	root, err := scxParse(strings.NewReader(src))
+	if err != nil {
+		return nil, err
+	}
+	g := &scxGrammar{defines: make(map[string]*scxNode)}
+	scxInheritNs(root, "")
+	for _, c := range root.children {
+		switch c.local {
+		case "start":
+			g.start = c
+		case "define":
+			g.defines[c.attr("name")] = c
+		}
+	}
+	if g.start == nil {
+		return nil, fmt.Errorf("schema has no start")
+	}
+	return g, nil
+}
+
+// scxInheritNs propagates the ns attribute to the element patterns.
+func scxInheritNs(n *scxNode, ns string) {
+	for _, a := range n.attrs {
+		if a.Name.Space == "" && a.Name.Local == "ns" {
+			ns = a.Value
+		}
+	}
+	if n.local == "element" && n.attr("ns") == "" {
+		n.attrs = append(n.attrs, xml.Attr{Name: xml.Name{Local: "ns"}, Value: ns})
+	}
+	for _, c := range n.children {
+		scxInheritNs(c, ns)
+	}
+}
+
+//--------validation
+
+type scxValidator struct {
+	g       *scxGrammar
+	lastErr error
+}
+
+// scxValidate checks an XML document against the schema.
+func scxValidate(schema string, r io.Reader) error {
+	g, err := scxCompile(schema)
+	if err != nil {
+		return err
+	}
+	inst, err := scxParse(r)
+	if err != nil {
+		return err
+	}
+	v := &scxValidator{g: g}
+	ends := v.match(g.start.children, []*scxNode{inst}, 0)
+	for _, e := range ends {
+		if e == 1 {
+			return nil
+		}
+	}
+	if v.lastErr != nil {
+		return v.lastErr
+	}
+	return fmt.Errorf("unexpected root element '%s'", inst.local)
+}
+
+// match returns all the possible positions after matching the
+// patterns against the elements from position i.
+func (v *scxValidator) match(pats []*scxNode, elems []*scxNode, i int) []int {
+	pos := []int{i}
+	for _, p := range pats {
+		var next []int
+		for _, j := range pos {
+			next = scxUnion(next, v.matchOne(p, elems, j))
+		}
+		if pos = next; len(pos) == 0 {
+			break
+		}
+	}
+	return pos
+}
+
+func (v *scxValidator) matchOne(p *scxNode, elems []*scxNode, i int) []int {
+	switch p.local {
+	case "element":
+		if i < len(elems) && elems[i].local == p.attr("name") && elems[i].space == p.attr("ns") {
+			if err := v.validateElement(p, elems[i]); err != nil {
+				v.lastErr = err
+				return nil
+			}
+			return []int{i + 1}
+		}
+		if i < len(elems) && v.lastErr == nil {
+			v.lastErr = fmt.Errorf("unexpected element '%s'", elems[i].local)
+		}
+		return nil
+	case "ref":
+		d := v.g.defines[p.attr("name")]
+		if d == nil {
+			v.lastErr = fmt.Errorf("undefined pattern '%s' in schema", p.attr("name"))
+			return nil
+		}
+		return v.match(d.children, elems, i)
+	case "group":
+		return v.match(p.children, elems, i)
+	case "optional":
+		return scxUnion([]int{i}, v.match(p.children, elems, i))
+	case "zeroOrMore", "oneOrMore":
+		var res []int
+		if p.local == "zeroOrMore" {
+			res = []int{i}
+		}
+		for pos := v.match(p.children, elems, i); len(pos) > 0; {
+			var next []int
+			for _, j := range pos {
+				if !scxContains(res, j) {
+					res = append(res, j)
+					next = scxUnion(next, v.match(p.children, elems, j))
+				}
+			}
+			pos = next
+		}
+		return res
+	case "choice":
+		var res []int
+		for _, c := range p.children {
+			res = scxUnion(res, v.match([]*scxNode{c}, elems, i))
+		}
+		return res
+	default: // attribute, text, empty, value, data
+		return []int{i}
+	}
+}
+
+func (v *scxValidator) validateElement(p, e *scxNode) error {
+	if err := v.validateAttrs(p, e); err != nil {
+		return err
+	}
+	if scxDeclaresEmpty(p.children) &&
+		(len(e.children) > 0 || strings.TrimSpace(e.text) != "") {
+		return fmt.Errorf("element '%s' must be empty", e.local)
+	}
+	ends := v.match(p.children, e.children, 0)
+	if !scxContains(ends, len(e.children)) {
+		if v.lastErr != nil {
+			return v.lastErr
+		}
+		return fmt.Errorf("invalid content in element '%s'", e.local)
+	}
+	text := strings.TrimSpace(e.text)
+	for _, c := range p.children {
+		switch c.local {
+		case "value", "data":
+			if !scxCheckValue(c, text) {
+				return fmt.Errorf("invalid content '%s' in element '%s'", text, e.local)
+			}
+			return nil
+		case "text":
+			return nil
+		}
+	}
+	if text != "" {
+		return fmt.Errorf("text is not allowed in element '%s'", e.local)
+	}
+	return nil
+}
+
+// scxDeclaresEmpty tells whether the patterns of an element declare
+// empty content, besides the attributes.
+func scxDeclaresEmpty(pats []*scxNode) bool {
+	empty := false
+	for _, c := range pats {
+		switch c.local {
+		case "empty":
+			empty = true
+		case "attribute":
+		case "optional":
+			if !scxOnlyAttrs(c.children) {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return empty
+}
+
+func scxOnlyAttrs(pats []*scxNode) bool {
+	for _, c := range pats {
+		if c.local != "attribute" {
+			return false
+		}
+	}
+	return true
+}
+
+func (v *scxValidator) validateAttrs(p, e *scxNode) error {
+	decls := make(map[string]*scxNode)
+	var required []string
+	var collect func(pats []*scxNode, opt bool)
+	collect = func(pats []*scxNode, opt bool) {
+		for _, c := range pats {
+			switch c.local {
+			case "attribute":
+				decls[c.attr("name")] = c
+				if !opt {
+					required = append(required, c.attr("name"))
+				}
+			case "optional", "zeroOrMore", "choice":
+				collect(c.children, true)
+			case "group", "oneOrMore":
+				collect(c.children, opt)
+			}
+		}
+	}
+	collect(p.children, false)
+	for _, a := range e.attrs {
+		d := decls[a.Name.Local]
+		if d == nil || a.Name.Space != "" {
+			return fmt.Errorf("unexpected attribute '%s' in element '%s'", a.Name.Local, e.local)
+		}
+		if !scxCheckContent(d.children, a.Value) {
+			return fmt.Errorf("invalid value '%s' of attribute '%s' in element '%s'",
+				a.Value, a.Name.Local, e.local)
+		}
+	}
+	for _, name := range required {
+		if _, ok := e.lookupAttr(name); !ok {
+			return fmt.Errorf("missing attribute '%s' in element '%s'", name, e.local)
+		}
+	}
+	return nil
+}
+
+// scxCheckContent checks a text value against the content patterns of
+// an attribute; the default content is text.
+func scxCheckContent(pats []*scxNode, val string) bool {
+	if len(pats) == 0 {
+		return true
+	}
+	for _, c := range pats {
+		switch c.local {
+		case "choice":
+			ok := false
+			for _, cc := range c.children {
+				if scxCheckValue(cc, val) {
+					ok = true
+				}
+			}
+			if !ok {
+				return false
+			}
+		default:
+			if !scxCheckValue(c, val) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+var rxXsdLanguage = regexp.MustCompile(`^[a-zA-Z]{1,8}(-[a-zA-Z0-9]{1,8})*$`)
+
+func scxCheckValue(p *scxNode, val string) bool {
+	switch p.local {
+	case "text":
+		return true
+	case "value":
+		return strings.TrimSpace(val) == strings.TrimSpace(p.text)
+	case "data":
+		ok := true
+		switch p.attr("type") {
+		case "language":
+			ok = rxXsdLanguage.MatchString(val)
+		case "integer", "positiveInteger", "nonNegativeInteger":
+			n, err := strconv.Atoi(val)
+			ok = err == nil
+			if p.attr("type") == "positiveInteger" {
+				ok = ok && n > 0
+			} else if p.attr("type") == "nonNegativeInteger" {
+				ok = ok && n >= 0
+			}
+		}
+		for _, c := range p.children {
+			if c.local == "param" && c.attr("name") == "pattern" {
+				rx, err := regexp.Compile("^(?:" + strings.TrimSpace(c.text) + ")$")
+				ok = ok && err == nil && rx.MatchString(val)
+			}
+		}
+		return ok
+	}
+	return false
+}
+
+func scxUnion(a, b []int) []int {
+	for _, v := range b {
+		if !scxContains(a, v) {
+			a = append(a, v)
+		}
+	}
+	return a
+}
+
+func scxContains(a []int, v int) bool {
+	for _, u := range a {
+		if u == v {
+			return true
+		}
+	}
+	return false
+}
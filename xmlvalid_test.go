@@ -0,0 +1,63 @@
+// Copyright (c) 2018-2021 Takayuki YATO (aka. "ZR")
+//   GitHub:   https://github.com/zr-tex8r/scsatysfi
+//   Twitter:  @zr_tex8r
+// Distributed under the MIT License.
+
+package main
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestScxSchemaFile(t *testing.T) {
+	data, err := ioutil.ReadFile("schema/document.rng")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != scxSchema {
+		t.Error("scxSchema differs from schema/document.rng")
+	}
+}
+
+func TestScxValidateAttrs(t *testing.T) {
+	schema := `<grammar xmlns="http://relaxng.org/ns/structure/1.0">
+  <start><element name="a"><attribute name="b"><text/></attribute></element></start>
+</grammar>`
+	tests := []struct {
+		doc string
+		ok  bool
+	}{
+		{`<a b="x"/>`, true},
+		{`<a b=""/>`, true},
+		{`<a/>`, false},
+	}
+	for _, tt := range tests {
+		err := scxValidate(schema, strings.NewReader(tt.doc))
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: got %v", tt.doc, err)
+		}
+	}
+}
+
+func TestScxValidateEmpty(t *testing.T) {
+	head := `<document xmlns="https://github.com/zr-tex8r/scsatysfi/ns/1" version="1" type="essential"
+ generator="scSATySFi" generator-version="0"><metadata><title>t</title><creator>c</creator>
+<lang>zxx</lang><source>s</source></metadata>`
+	tests := []struct {
+		snowman string
+		ok      bool
+	}{
+		{`<snowman line="1" column="0"/>`, true},
+		{`<snowman line="1" column="0"> </snowman>`, true},
+		{`<snowman line="1" column="0"><snowman line="1" column="0"/></snowman>`, false},
+		{`<snowman line="1" column="0">x</snowman>`, false},
+	}
+	for _, tt := range tests {
+		err := scxValidate(scxSchema, strings.NewReader(head+tt.snowman+"</document>"))
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: got %v", tt.snowman, err)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+// Copyright (c) 2018-2021 Takayuki YATO (aka. "ZR")
+//   GitHub:   https://github.com/zr-tex8r
+//   Twitter:  @zr_tex8r
+// Distributed under the MIT License.
+
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"time"
+)
+
+// sczPackage builds a ZIP-based document package, such as EPUB or ODF.
+type sczPackage struct {
+	buf   *bytes.Buffer
+	zw    *zip.Writer
+	mtime time.Time
+}
+
+func newSczPackage() *sczPackage {
+	buf := new(bytes.Buffer)
+	return &sczPackage{buf, zip.NewWriter(buf), time.Now()}
+}
+
+// addMimetype adds the "mimetype" entry, which must come first and be
+// stored without compression or extra fields; the time is left unset,
+// for it would be put in an extra field.
+func (p *sczPackage) addMimetype(mtype string) {
+	fh := &zip.FileHeader{Name: "mimetype", Method: zip.Store}
+	w, err := p.zw.CreateHeader(fh)
+	sceAssert(err)
+	_, err = w.Write([]byte(mtype))
+	sceAssert(err)
+}
+
+// add adds a compressed entry.
+func (p *sczPackage) add(name string, data []byte) {
+	fh := &zip.FileHeader{Name: name, Method: zip.Deflate}
+	fh.Modified = p.mtime
+	w, err := p.zw.CreateHeader(fh)
+	sceAssert(err)
+	_, err = w.Write(data)
+	sceAssert(err)
+}
+
+func (p *sczPackage) addString(name, text string) {
+	p.add(name, []byte(text))
+}
+
+// bytes finishes the package and returns its content.
+func (p *sczPackage) bytes() []byte {
+	sceAssert(p.zw.Close())
+	return p.buf.Bytes()
+}